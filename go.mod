@@ -11,6 +11,7 @@ require (
 	github.com/go-stack/stack v1.8.1
 	github.com/gorilla/sessions v1.4.0
 	github.com/hashicorp/hcl v1.0.0
+	github.com/jmespath/go-jmespath v0.4.0
 	github.com/markbates/goth v1.80.0
 	github.com/pkg/errors v0.9.1
 	github.com/rs/xid v1.6.0
@@ -32,7 +33,6 @@ require (
 	github.com/gorilla/context v1.1.1 // indirect
 	github.com/gorilla/mux v1.6.2 // indirect
 	github.com/gorilla/securecookie v1.1.2 // indirect
-	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/josharian/intern v1.0.0 // indirect
 	github.com/mailru/easyjson v0.7.7 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
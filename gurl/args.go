@@ -0,0 +1,118 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+var (
+	subcommand     = ""
+	scriptName     = ""
+	cmdLineOptions = []string{}
+
+	// options that consume the following argument as their value
+	valueOptions = map[string]bool{
+		"-report":  true,
+		"-history": true,
+		"-load":    true,
+		"-session": true,
+		"-record":  true,
+		"-replay":  true,
+		"-env":     true,
+		"-secrets": true,
+		"-d":       true,
+		"-section": true,
+
+		"-parallel": true,
+		"-run":      true,
+		"-tags":     true,
+
+		"-load-worker": true,
+
+		// the subcommands' ones
+		"-format":  true,
+		"--format": true,
+		"-output":  true,
+		"--output": true,
+		"-o":       true,
+
+		"-environment":  true,
+		"--environment": true,
+
+		"-openapi":  true,
+		"--openapi": true,
+		"-by":       true,
+		"--by":      true,
+		"-port":     true,
+		"--port":    true,
+	}
+)
+
+// parseArguments separates the name of the script from the options,
+// which may appear either before or after it
+func parseArguments() {
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if _, found := subcommands[lower(args[0])]; found {
+			subcommand = lower(args[0])
+			args = args[1:]
+		}
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, "-") {
+			cmdLineOptions = append(cmdLineOptions, arg)
+			if valueOptions[lower(arg)] && i+1 < len(args) {
+				i++
+				cmdLineOptions = append(cmdLineOptions, args[i])
+			}
+			continue
+		}
+		if len(scriptName) == 0 {
+			scriptName = arg
+		} else {
+			cmdLineOptions = append(cmdLineOptions, arg)
+		}
+	}
+}
+
+func helpRequested() bool {
+	if len(scriptName) == 0 {
+		// the spec of generate may come with --openapi instead, settings and test need no script at all
+		if _, found := optionValue("--openapi", "-openapi"); (!found || subcommand != "generate") && subcommand != "settings" && subcommand != "test" {
+			return true
+		}
+	} else if help(scriptName) {
+		return true
+	}
+	for _, option := range cmdLineOptions {
+		switch lower(option) {
+		case "-h", "-help", "--help", "/help":
+			return true
+		}
+	}
+	return false
+}
+
+// optionValue returns the value of the given option, which is either
+// in the same argument (--format=curl) or in the following one (--format curl)
+func optionValue(names ...string) (string, bool) {
+	for i := 0; i < len(cmdLineOptions); i++ {
+		option, value := cmdLineOptions[i], ""
+		if equal := strings.Index(option, "="); equal > 0 {
+			option, value = option[:equal], option[equal+1:]
+		} else if valueOptions[lower(option)] && i+1 < len(cmdLineOptions) {
+			value = cmdLineOptions[i+1]
+		}
+		for _, name := range names {
+			if lower(option) == name {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
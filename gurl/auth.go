@@ -0,0 +1,284 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// authenticator adds the credentials (or the signature) to the request right before it is sent
+type authenticator interface {
+	authenticate(request *http.Request, body []byte)
+}
+
+type (
+	basicAuth struct {
+		user     string
+		password string
+	}
+
+	bearerAuth struct {
+		token string
+	}
+
+	oauth2Auth struct {
+		settings m2s
+
+		token   string
+		refresh string
+		expires time.Time
+	}
+
+	awsAuth struct {
+		settings m2s
+	}
+
+	hmacAuth struct {
+		settings m2s
+	}
+)
+
+var (
+	currentAuth authenticator
+)
+
+// note: the values are expanded right before every request, so they may refer to the variables set later
+
+func (auth *basicAuth) authenticate(request *http.Request, _ []byte) {
+	request.SetBasicAuth(expand(auth.user), expand(auth.password))
+}
+
+func (auth *bearerAuth) authenticate(request *http.Request, _ []byte) {
+	request.Header.Set("Authorization", "Bearer "+expand(auth.token))
+}
+
+func (auth *oauth2Auth) authenticate(request *http.Request, _ []byte) {
+	if len(auth.token) == 0 || time.Now().After(auth.expires) {
+		auth.fetchToken()
+	}
+	request.Header.Set("Authorization", "Bearer "+auth.token)
+}
+
+func (auth *oauth2Auth) fetchToken() {
+	settings := expandSettings(auth.settings)
+	tokenUrl := settings["token.url"]
+	if len(tokenUrl) == 0 {
+		quit("AUTH oauth2 requires token.url")
+	}
+
+	form := url.Values{}
+	grant := settings["grant"]
+	switch {
+	case len(auth.refresh) > 0:
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", auth.refresh)
+	case grant == "client_credentials":
+		form.Set("grant_type", grant)
+	case grant == "password":
+		form.Set("grant_type", grant)
+		form.Set("username", settings["username"])
+		form.Set("password", settings["password"])
+	default:
+		quit("AUTH oauth2 has unsupported grant [%s], expected: client_credentials or password", grant)
+	}
+	if scope := settings["scope"]; len(scope) > 0 {
+		form.Set("scope", scope)
+	}
+	if audience := settings["audience"]; len(audience) > 0 {
+		form.Set("audience", audience)
+	}
+
+	useBasic := lower(settings["client.auth"]) == "basic"
+	if !useBasic {
+		form.Set("client_id", settings["client.id"])
+		if secret := settings["client.secret"]; len(secret) > 0 {
+			form.Set("client_secret", secret)
+		}
+	}
+
+	request, err := http.NewRequest(http.MethodPost, tokenUrl, strings.NewReader(form.Encode()))
+	quitOnError(err, "Creating token request to [%s]", tokenUrl)
+	request.Header.Set(headerContentType, contentTypeForm)
+	request.Header.Set("Accept", contentTypeJson)
+	request.Header.Set("User-Agent", userAgent)
+	if useBasic {
+		request.SetBasicAuth(url.QueryEscape(settings["client.id"]), url.QueryEscape(settings["client.secret"]))
+	}
+
+	resp, err := httpClient().Do(request)
+	quitOnError(err, "Requesting token from [%s]", tokenUrl)
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	quitOnError(err, "Reading token response from [%s]", tokenUrl)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		if len(auth.refresh) > 0 {
+			// the refresh token is no good, start over
+			debug("refreshing token failed with %s", resp.Status)
+			auth.refresh = ""
+			auth.fetchToken()
+			return
+		}
+		quit("token request to [%s] failed with %s: %s", tokenUrl, resp.Status, string(data))
+	}
+
+	var token struct {
+		AccessToken  string      `json:"access_token"`
+		RefreshToken string      `json:"refresh_token"`
+		ExpiresIn    json.Number `json:"expires_in"`
+	}
+	quitOnError(json.Unmarshal(data, &token), "Parsing token response from [%s]", tokenUrl)
+	if len(token.AccessToken) == 0 {
+		quit("token response from [%s] has no access_token", tokenUrl)
+	}
+
+	auth.token = token.AccessToken
+	auth.refresh = token.RefreshToken
+	auth.expires = time.Now().Add(oauth2TokenLifetimeDefault)
+	if seconds, err := token.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		auth.expires = time.Now().Add(time.Duration(seconds)*time.Second - oauth2ExpirySkew)
+	}
+	debug("got a new token from [%s], valid until %s", tokenUrl, auth.expires.Format(time.RFC3339))
+}
+
+func (auth *awsAuth) authenticate(request *http.Request, body []byte) {
+	settings := expandSettings(auth.settings)
+	region, service := settings["region"], settings["service"]
+	if len(region) == 0 {
+		region = settings["aws.region"]
+	}
+	if len(region) == 0 || len(service) == 0 {
+		quit("AUTH aws requires region and service")
+	}
+
+	var creds *credentials.Credentials
+	if key := settings["access.key"]; len(key) > 0 {
+		creds = credentials.NewStaticCredentials(key, settings["secret.key"], settings["session.token"])
+	} else {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvProvider{},
+			&credentials.SharedCredentialsProvider{Profile: settings["profile"]},
+		})
+	}
+
+	signer := v4.NewSigner(creds)
+	_, err := signer.Sign(request, bytes.NewReader(body), service, region, time.Now())
+	quitOnError(err, "Signing the request with AWS Signature V4")
+}
+
+// the signature is calculated over the canonical string, where the placeholders are replaced with the request's values
+func (auth *hmacAuth) authenticate(request *http.Request, body []byte) {
+	settings := expandSettings(auth.settings)
+	key := settings["key"]
+	if len(key) == 0 {
+		quit("AUTH hmac requires key")
+	}
+
+	header := settings["header"]
+	if len(header) == 0 {
+		header = "Authorization"
+	}
+	canonical := settings["canonical"]
+	if len(canonical) == 0 {
+		canonical = hmacCanonicalDefault
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	if name := settings["timestamp.header"]; len(name) > 0 {
+		request.Header.Set(name, timestamp)
+	}
+
+	bodyHash := sha256.Sum256(body)
+	replacer := strings.NewReplacer(
+		"{method}", request.Method,
+		"{path}", request.URL.EscapedPath(),
+		"{query}", request.URL.RawQuery,
+		"{host}", request.URL.Host,
+		"{url}", request.URL.String(),
+		"{timestamp}", timestamp,
+		"{date}", time.Now().UTC().Format(http.TimeFormat),
+		"{body}", string(body),
+		"{body.sha256}", hex.EncodeToString(bodyHash[:]),
+		`\n`, "\n",
+	)
+	message := replacer.Replace(canonical)
+
+	signer := hmac.New(hashFunction(settings["algorithm"]), []byte(key))
+	signer.Write([]byte(message))
+	signature := signer.Sum(nil)
+
+	encoded := hex.EncodeToString(signature)
+	if lower(settings["encoding"]) == "base64" {
+		encoded = base64.StdEncoding.EncodeToString(signature)
+	}
+	request.Header.Set(header, settings["prefix"]+encoded)
+	debug("HMAC canonical string: [%s]", message)
+}
+
+func hashFunction(name string) func() hash.Hash {
+	switch lower(name) {
+	case "", "sha256":
+		return sha256.New
+	case "sha1":
+		return sha1.New
+	case "sha512":
+		return sha512.New
+	case "md5":
+		return md5.New
+	}
+	quit("unsupported hash algorithm [%s]", name)
+	return nil
+}
+
+func expandSettings(settings m2s) m2s {
+	result := make(m2s, len(settings))
+	for key, value := range settings {
+		result[key] = expand(value)
+	}
+	return result
+}
+
+// authHeaders returns the headers the current authenticator adds to the request (used for -curl)
+func authHeaders(verb, fullUrl, data string) http.Header {
+	if currentAuth == nil {
+		return nil
+	}
+	body := []byte(loadExternalFile(data))
+	request, err := http.NewRequest(strings.ToUpper(verb), fullUrl, bytes.NewReader(body))
+	quitOnError(err, "Creating request to [%s]", fullUrl)
+	for key, value := range headers {
+		if len(key) > 0 && len(value) > 0 {
+			request.Header.Set(key, expand(value))
+		}
+	}
+	before := request.Header.Clone()
+	currentAuth.authenticate(request, body)
+
+	added := http.Header{}
+	for key, values := range request.Header {
+		if strings.Join(before[key], ",") != strings.Join(values, ",") {
+			added[key] = values
+		}
+	}
+	return added
+}
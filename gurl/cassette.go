@@ -0,0 +1,301 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// gurl -record cassette.json script.gurl
+// gurl -replay cassette.json script.gurl
+//
+// SET replay.ignore.query timestamp,nonce		(the query parameters left out of the matching)
+// SET replay.ignore.body meta/requestId,sentAt	(the json fields left out of the matching, * for the whole body)
+// SET replay.ignore.host yes					(the scheme and host are left out of the matching)
+//
+// the exchanges are recorded (and replayed) at the transport level, so every redirect is an exchange of its own.
+// the requests are matched on the method, the url and the body; the same request recorded more than once
+// gets its responses in the recorded order (the last one is repeated after that).
+
+type (
+	cassette struct {
+		Recorded     string          `json:"recorded"`
+		Interactions []SavedResponse `json:"interactions"`
+	}
+
+	recordingTransport struct {
+		next http.RoundTripper
+	}
+
+	replayingTransport struct{}
+)
+
+const cassetteEncodingBase64 = "base64"
+
+var (
+	recordFile = ""
+	replayFile = ""
+
+	recorded = &cassette{}
+	replayed = &cassette{}
+	// the interactions served already
+	replayUsed  = map[int]bool{}
+	replayCount = 0
+	cassetteMu  sync.Mutex
+
+	replayIgnoreQuery = ""
+	replayIgnoreBody  = ""
+	replayIgnoreHost  = false
+
+	// never written into a cassette
+	cassetteSkippedHeaders = []string{headerAuthorization, "Cookie", "Proxy-Authorization"}
+)
+
+func enableRecording(file string) {
+	if len(replayFile) > 0 {
+		quit("-record and -replay cannot be used together")
+	}
+	recordFile = file
+	recorded.Recorded = time.Now().UTC().Format(time.RFC3339)
+	onExit(saveCassette)
+}
+
+func enableReplay(file string) {
+	if len(recordFile) > 0 {
+		quit("-record and -replay cannot be used together")
+	}
+	data, err := ioutil.ReadFile(file)
+	quitOnError(err, "Reading cassette [%s]", file)
+	quitOnError(json.Unmarshal(data, replayed), "Parsing cassette [%s]", file)
+	replayFile = file
+	onExit(func() {
+		if loadTesting() {
+			// the load test workers do the replaying
+			return
+		}
+		comment(echoProgress, "replayed %v requests from %s (%v interactions)", replayCount, replayFile, len(replayed.Interactions))
+	})
+}
+
+// cassetteTransport wraps the transport of the client when recording or replaying
+func cassetteTransport(transport http.RoundTripper) http.RoundTripper {
+	switch {
+	case len(replayFile) > 0:
+		return &replayingTransport{}
+	case len(recordFile) > 0:
+		return &recordingTransport{next: transport}
+	}
+	return transport
+}
+
+func (transport *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	body, err := requestBody(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := transport.next.RoundTrip(request)
+	if err != nil {
+		return resp, err
+	}
+
+	var data []byte
+	if resp.Body != nil {
+		data, err = ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+
+	saved := SavedResponse{}
+	saved.Request.Method = request.Method
+	saved.Request.Url = request.URL.String()
+	saved.Request.Header = request.Header.Clone()
+	for _, name := range cassetteSkippedHeaders {
+		saved.Request.Header.Del(name)
+	}
+	saved.Request.Body, saved.Request.Encoding = cassetteText(body)
+	saved.Response.Status = resp.Status
+	saved.Response.StatusCode = resp.StatusCode
+	saved.Response.Header = resp.Header.Clone()
+	saved.Response.Body, saved.Response.Encoding = cassetteText(data)
+
+	cassetteMu.Lock()
+	recorded.Interactions = append(recorded.Interactions, saved)
+	cassetteMu.Unlock()
+	return resp, nil
+}
+
+func (transport *replayingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	body, err := requestBody(request)
+	if err != nil {
+		return nil, err
+	}
+	key := cassetteKey(request.Method, request.URL.String(), request.Header.Get(headerContentType), body)
+
+	cassetteMu.Lock()
+	defer cassetteMu.Unlock()
+	found, last := -1, -1
+	for at, one := range replayed.Interactions {
+		data, err := cassetteBytes(one.Request.Body, one.Request.Encoding)
+		if err != nil || cassetteKey(one.Request.Method, one.Request.Url, one.Request.Header.Get(headerContentType), data) != key {
+			continue
+		}
+		last = at
+		if !replayUsed[at] {
+			found = at
+			break
+		}
+	}
+	if found < 0 {
+		found = last
+	}
+	if found < 0 {
+		return nil, fmt.Errorf("there is no recorded response to %s %s in [%s]", request.Method, request.URL.String(), replayFile)
+	}
+	replayUsed[found] = true
+	replayCount++
+
+	saved := replayed.Interactions[found].Response
+	data, err := cassetteBytes(saved.Body, saved.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	debug("replaying interaction #%v for %s %s", found+1, request.Method, request.URL.String())
+	return &http.Response{
+		Status:        saved.Status,
+		StatusCode:    saved.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        saved.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       request,
+	}, nil
+}
+
+// requestBody reads the body of the request and puts it back
+func requestBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+	data, err := ioutil.ReadAll(request.Body)
+	_ = request.Body.Close()
+	request.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, err
+}
+
+// cassetteKey is what the requests are matched on (after the ignore rules are applied)
+func cassetteKey(method, target, contentType string, body []byte) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return method + " " + target
+	}
+	query := u.Query()
+	for _, name := range splitList(replayIgnoreQuery) {
+		query.Del(name)
+	}
+	u.RawQuery = query.Encode()
+	if replayIgnoreHost {
+		u.Scheme, u.Host = "", ""
+	}
+	return method + " " + u.String() + "\n" + cassetteBodyKey(contentType, body)
+}
+
+func cassetteBodyKey(contentType string, body []byte) string {
+	ignored := splitList(replayIgnoreBody)
+	if len(ignored) == 1 && ignored[0] == includeAllKey {
+		return ""
+	}
+
+	// the boundaries are random
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if boundary := params["boundary"]; strings.HasPrefix(mediaType, "multipart/") && len(boundary) > 0 {
+		return string(bytes.Replace(body, []byte(boundary), []byte("boundary"), -1))
+	}
+
+	var document interface{}
+	if len(ignored) == 0 || json.Unmarshal(body, &document) != nil {
+		return string(body)
+	}
+	for _, path := range ignored {
+		removeJsonPath(document, strings.Split(path, itemsSeparator))
+	}
+	// the keys come out sorted
+	canonical, _ := json.Marshal(document)
+	return string(canonical)
+}
+
+func removeJsonPath(document interface{}, steps []string) {
+	object, ok := document.(map[string]interface{})
+	if !ok || len(steps) == 0 {
+		return
+	}
+	if len(steps) == 1 {
+		delete(object, steps[0])
+		return
+	}
+	if list, ok := object[steps[0]].([]interface{}); ok {
+		for _, item := range list {
+			removeJsonPath(item, steps[1:])
+		}
+		return
+	}
+	removeJsonPath(object[steps[0]], steps[1:])
+}
+
+func splitList(src string) []string {
+	result := []string{}
+	for _, one := range strings.Split(src, ",") {
+		if one = strings.TrimSpace(one); len(one) > 0 {
+			result = append(result, one)
+		}
+	}
+	return result
+}
+
+// cassetteText keeps the text as it is and the binary data in base64
+func cassetteText(data []byte) (string, string) {
+	if utf8.Valid(data) {
+		return string(data), ""
+	}
+	return base64.StdEncoding.EncodeToString(data), cassetteEncodingBase64
+}
+
+func cassetteBytes(text, encoding string) ([]byte, error) {
+	if encoding == cassetteEncodingBase64 {
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return []byte(text), nil
+}
+
+func saveCassette() {
+	if offline() || loadTesting() {
+		return
+	}
+	data, err := json.MarshalIndent(recorded, marshalPrefix, marshalIndent)
+	if err != nil {
+		reportError(err, "Marshalling cassette")
+		return
+	}
+	if err := ioutil.WriteFile(recordFile, data, 0644); err != nil {
+		reportError(err, "Saving cassette to [%s]", recordFile)
+		return
+	}
+	report("%v exchanges recorded to %s", len(recorded.Interactions), recordFile)
+}
@@ -0,0 +1,200 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SET http.timeout 30s
+// SET insecure.skip.verify yes
+// SET tls.ca.file ./ca.pem
+// SET tls.client.cert ./client.pem
+// SET tls.client.key ./client.key
+// SET http.proxy http://proxy:3128		(or "none" to ignore the environment)
+// SET follow.redirects no
+// SET max.redirects 3
+// SET http2 no
+
+var (
+	httpTimeout     = ""
+	tlsCaFile       = ""
+	tlsClientCert   = ""
+	tlsClientKey    = ""
+	httpProxy       = ""
+	maxRedirects    = ""
+	skipTlsVerify   = skipTlsVerifyDefault
+	followRedirects = followRedirectsDefault
+	enableHttp2     = enableHttp2Default
+
+	// the client is reused (to keep the connections alive) while its settings stay the same
+	sharedClient         *http.Client
+	sharedClientSettings = ""
+)
+
+// knobs are the non-binary counterparts of the dials
+var knobs = map[string]*string{
+	"http.timeout":    &httpTimeout,
+	"tls.ca.file":     &tlsCaFile,
+	"tls.client.cert": &tlsClientCert,
+	"tls.client.key":  &tlsClientKey,
+	"http.proxy":      &httpProxy,
+	"max.redirects":   &maxRedirects,
+
+	"replay.ignore.query": &replayIgnoreQuery,
+	"replay.ignore.body":  &replayIgnoreBody,
+}
+
+func httpClient() *http.Client {
+	settings := fmt.Sprint(httpTimeout, tlsCaFile, tlsClientCert, tlsClientKey, httpProxy, maxRedirects,
+		skipTlsVerify, followRedirects, enableHttp2, useCookieJar)
+	if sharedClient == nil || settings != sharedClientSettings {
+		sharedClient = newHttpClient()
+		sharedClientSettings = settings
+	}
+	return sharedClient
+}
+
+func newHttpClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = newTlsConfig()
+
+	if !enableHttp2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	switch lower(httpProxy) {
+	case "":
+		transport.Proxy = http.ProxyFromEnvironment
+	case "none", "off", "direct":
+		transport.Proxy = nil
+	default:
+		proxy, err := url.Parse(expand(httpProxy))
+		quitOnError(err, "Parsing proxy url [%s]", httpProxy)
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	client := &http.Client{Transport: cassetteTransport(transport)}
+	if useCookieJar {
+		client.Jar = cookieJar
+	}
+
+	if len(httpTimeout) > 0 {
+		timeout, err := time.ParseDuration(httpTimeout)
+		quitOnError(err, "Parsing http.timeout [%s]", httpTimeout)
+		client.Timeout = timeout
+	}
+
+	limit := -1
+	if len(maxRedirects) > 0 {
+		value, err := strconv.Atoi(maxRedirects)
+		quitOnError(err, "Parsing max.redirects [%s]", maxRedirects)
+		limit = value
+	}
+	client.CheckRedirect = func(request *http.Request, via []*http.Request) error {
+		if !followRedirects || limit == 0 {
+			return http.ErrUseLastResponse
+		}
+		if limit > 0 && len(via) > limit {
+			return errors.New("stopped after " + strconv.Itoa(limit) + " redirects")
+		}
+		if limit < 0 && len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return client
+}
+
+func newTlsConfig() *tls.Config {
+	config := &tls.Config{InsecureSkipVerify: skipTlsVerify}
+
+	if len(tlsCaFile) > 0 {
+		name, err := expandPath(expand(tlsCaFile))
+		quitOnError(err, "Failed to process file [%s]", tlsCaFile)
+		data, err := ioutil.ReadFile(name)
+		quitOnError(err, "Reading CA bundle [%s]", name)
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			quit("no certificates found in CA bundle [%s]", name)
+		}
+		config.RootCAs = pool
+	}
+
+	if len(tlsClientCert) > 0 || len(tlsClientKey) > 0 {
+		if len(tlsClientCert) == 0 || len(tlsClientKey) == 0 {
+			quit("both tls.client.cert and tls.client.key are required")
+		}
+		cert, err := expandPath(expand(tlsClientCert))
+		quitOnError(err, "Failed to process file [%s]", tlsClientCert)
+		key, err := expandPath(expand(tlsClientKey))
+		quitOnError(err, "Failed to process file [%s]", tlsClientKey)
+
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		quitOnError(err, "Loading client certificate [%s] and key [%s]", cert, key)
+		config.Certificates = []tls.Certificate{pair}
+	}
+	return config
+}
+
+// curlClientOptions mirrors the client settings in the generated curl commands
+func curlClientOptions() []string {
+	options := []string{}
+	if len(httpTimeout) > 0 {
+		if timeout, err := time.ParseDuration(httpTimeout); err == nil {
+			options = append(options, fmt.Sprintf("--max-time %v", timeout.Seconds()))
+		}
+	}
+	if skipTlsVerify {
+		options = append(options, "--insecure")
+	}
+	if len(tlsCaFile) > 0 {
+		options = append(options, fmt.Sprintf("--cacert '%s'", tlsCaFile))
+	}
+	if len(tlsClientCert) > 0 {
+		options = append(options, fmt.Sprintf("--cert '%s' --key '%s'", tlsClientCert, tlsClientKey))
+	}
+	switch lower(httpProxy) {
+	case "":
+	case "none", "off", "direct":
+		options = append(options, "--noproxy '*'")
+	default:
+		options = append(options, fmt.Sprintf("--proxy '%s'", httpProxy))
+	}
+	if followRedirects && maxRedirects != "0" {
+		options = append(options, "--location")
+		if len(maxRedirects) > 0 {
+			options = append(options, "--max-redirs "+maxRedirects)
+		}
+	}
+	if !enableHttp2 {
+		options = append(options, "--http1.1")
+	}
+	return options
+}
+
+func setKnob(key, value string) bool {
+	for name, knob := range knobs {
+		if lower(key) == name {
+			*knob = strings.TrimSpace(value)
+			return true
+		}
+	}
+	return false
+}
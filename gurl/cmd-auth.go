@@ -0,0 +1,68 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+)
+
+// AUTH basic ${user} ${password}
+// AUTH bearer ${token}
+// AUTH oauth2 grant=client_credentials token.url=https://auth/token client.id=${id} client.secret=${secret} scope=read
+// AUTH oauth2 grant=password token.url=... client.id=... username=${user} password=${password} [client.auth=basic]
+// AUTH aws region=us-east-1 service=execute-api [access.key=... secret.key=... session.token=... profile=...]
+// AUTH hmac key=${secret} [header=X-Signature] [algorithm=sha256|sha1|sha512|md5] [encoding=hex|base64] [prefix="HMAC "]
+//      [timestamp.header=X-Timestamp] [canonical="{method}\n{path}\n{query}\n{host}\n{timestamp}\n{date}\n{body}\n{body.sha256}"]
+// AUTH none
+//
+// the values are expanded when the request is sent
+
+func processAuth(params, options string) {
+	scheme, remainder := split(params)
+	comment(echoAuthCommand, "AUTH command: %s", scheme)
+
+	args := splitArguments(remainder)
+	switch lower(scheme) {
+	case "none", "off", "":
+		currentAuth = nil
+
+	case "basic":
+		if len(args) != 2 {
+			quit("AUTH basic expects: user password")
+		}
+		currentAuth = &basicAuth{user: args[0], password: args[1]}
+
+	case "bearer":
+		if len(args) != 1 {
+			quit("AUTH bearer expects: token")
+		}
+		currentAuth = &bearerAuth{token: args[0]}
+
+	case "oauth2":
+		currentAuth = &oauth2Auth{settings: authSettings(args)}
+
+	case "aws", "sigv4":
+		currentAuth = &awsAuth{settings: authSettings(args)}
+
+	case "hmac":
+		currentAuth = &hmacAuth{settings: authSettings(args)}
+
+	default:
+		quit("unknown AUTH scheme [%s], expected: basic, bearer, oauth2, aws, hmac or none", scheme)
+	}
+}
+
+func authSettings(args []string) m2s {
+	settings := m2s{}
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			quit("AUTH expects key=value, got [%s]", arg)
+		}
+		// not trimmed: the value may deliberately end with a space (e.g. prefix="HMAC ")
+		settings[lower(parts[0])] = parts[1]
+	}
+	return settings
+}
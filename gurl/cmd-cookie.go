@@ -0,0 +1,69 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// COOKIE SET sid abc123 [https://other.host/path]	- the base url is used by default
+// COOKIE DELETE sid [url]
+// COOKIE CLEAR
+// COOKIE LIST
+
+func processCookie(params, options string) {
+	comment(echoCookieCommand, "COOKIE command: %s", params)
+
+	action, remainder := split(expand(params))
+	switch lower(action) {
+	case "set":
+		args := splitArguments(remainder)
+		if len(args) < 2 || len(args) > 3 {
+			quit("COOKIE SET expects: name value [url]")
+		}
+		target := cookieUrl(args[2:])
+		cookieJar.SetCookies(target, []*http.Cookie{{Name: args[0], Value: args[1], Path: "/"}})
+
+	case "delete", "remove":
+		args := splitArguments(remainder)
+		if len(args) < 1 || len(args) > 2 {
+			quit("COOKIE DELETE expects: name [url]")
+		}
+		target := cookieUrl(args[1:])
+		cookieJar.SetCookies(target, []*http.Cookie{{Name: args[0], Path: "/", MaxAge: -1}})
+
+	case "clear":
+		cookieJar = newCookieJar()
+		sharedClient = nil
+
+	case "list", "show", "":
+		if offline() {
+			return
+		}
+		cookies := cookieJar.all()
+		if len(cookies) == 0 {
+			report("Cookie jar is empty.")
+		}
+		for _, name := range cookieJar.sortedUrls() {
+			for _, cookie := range cookies[name] {
+				report("\tCookie: %s [%s] = [%s]", name, cookie.Name, cookie.Value)
+			}
+		}
+
+	default:
+		quit("unknown COOKIE action [%s]", action)
+	}
+}
+
+func cookieUrl(args []string) *url.URL {
+	target := expand(baseUrl)
+	if len(args) > 0 {
+		target = args[0]
+	}
+	u, err := url.Parse(target)
+	quitOnError(err, "Parsing url [%s]", target)
+	return u
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DEFINE create_tenant(name, plan)
+//     POST:tenant /v1/tenants {"name": "${name}", "plan": "${plan}"}
+//     REQUIRE ${status} 201
+// END
+//
+// CALL create_tenant acme "gold plan"
+
+type definition struct {
+	name   string
+	params []string
+	body   []statement
+}
+
+var (
+	definitions = map[string]*definition{}
+
+	definePattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.-]*)\s*(?:\((.*)\))?$`)
+)
+
+func processDefine(opener statement, body []statement, _ statement) {
+	_, params := split(opener.text)
+	comment(echoDefineCommand, "DEFINE: %s", params)
+
+	match := definePattern.FindStringSubmatch(strings.TrimSpace(params))
+	if match == nil {
+		quit("DEFINE has wrong signature [%s], expected: DEFINE name(arg1, arg2)", params)
+	}
+
+	what := &definition{name: match[1], body: body}
+	for _, param := range strings.Split(match[2], ",") {
+		if param = strings.TrimSpace(param); len(param) > 0 {
+			what.params = append(what.params, param)
+		}
+	}
+
+	if _, found := definitions[lower(what.name)]; found {
+		debug("redefining [%s]", what.name)
+	}
+	definitions[lower(what.name)] = what
+}
+
+func processCall(params, options string) {
+	comment(echoCallCommand, "CALL: %s", params)
+
+	name, remainder := split(params)
+	what, found := definitions[lower(name)]
+	if !found {
+		quit("CALL of unknown definition [%s]", name)
+	}
+
+	args := splitArguments(remainder)
+	if len(args) != len(what.params) {
+		quit("[%s] expects %v argument(s), got %v", what.name, len(what.params), len(args))
+	}
+
+	bound := msi{}
+	for index, param := range what.params {
+		bound[param] = expand(args[index])
+	}
+
+	enter("called")
+	defer leave()
+
+	pushScope(bound)
+	defer popScope()
+
+	runStatements(what.body)
+}
+
+// splitArguments breaks the text into the space-separated arguments, respecting double quotes
+func splitArguments(src string) []string {
+	args := []string{}
+	current := strings.Builder{}
+	quoted, started := false, false
+
+	for _, r := range src {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case !quoted && strings.ContainsRune(wordSeparator, r):
+			if started {
+				args = append(args, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		quit("unbalanced quotes in [%s]", src)
+	}
+	if started {
+		args = append(args, current.String())
+	}
+	return args
+}
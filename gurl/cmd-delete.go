@@ -6,5 +6,5 @@ package main
 
 func processDelete(params, options string) {
 	comment(echoDeleteCommand, "DELETE command: %s", params)
-	call(params, "DELETE", "")
+	call(params, "DELETE", "", options)
 }
@@ -12,6 +12,7 @@ func processEcho(params, options string) {
 }
 
 func processSection(params, options string) {
+	startSection(expand(params))
 	if offline() {
 		return
 	}
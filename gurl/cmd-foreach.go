@@ -0,0 +1,45 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// GET /orders
+//
+// FOREACH order IN ${response:items}
+//     DELETE /orders/${order:id}
+//
+//     ECHO deleted order ${order.index}: ${order:id}
+// END
+
+func processForeach(opener statement, body []statement, _ statement) {
+	_, params := split(opener.text)
+	comment(echoForeachCommand, "FOREACH: %s", params)
+
+	name, remainder := split(params)
+	keyword, source := split(remainder)
+	if len(name) == 0 || lower(keyword) != "in" || len(source) == 0 {
+		quit("FOREACH command has wrong arguments [%s], expected: FOREACH name IN ${...}", params)
+	}
+
+	what := resolveOperand(source)
+	if !what.found {
+		debug("FOREACH source [%s] is absent, nothing to iterate over", source)
+		return
+	}
+	items, converts := what.value.(slice)
+	if !converts {
+		quit("FOREACH source [%s] is not an array", source)
+	}
+
+	for index, item := range items {
+		pushScope(msi{
+			name:            item,
+			name + ".index": index,
+			name + ".count": len(items),
+		})
+		runStatements(body)
+		popScope()
+		locate(opener)
+	}
+}
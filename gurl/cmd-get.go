@@ -6,5 +6,5 @@ package main
 
 func processGet(params, options string) {
 	comment(echoGetCommand, "GET command: %s", params)
-	call(params, "GET", "")
+	call(params, "GET", "", options)
 }
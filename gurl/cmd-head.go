@@ -0,0 +1,10 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+func processHead(params, options string) {
+	comment(echoHeadCommand, "HEAD command: %s", params)
+	call(params, "HEAD", "", options)
+}
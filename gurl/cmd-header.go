@@ -15,6 +15,9 @@ func processHeader(params, options string) {
 	if len(key) == 0 {
 		quit("Header name cannot be empty/absent")
 	}
+	if isOverridden(configurationHeaderPrefix + key) {
+		return
+	}
 
 	if len(value) == 0 {
 		delete(headers, key)
@@ -0,0 +1,46 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// IF ${status} == 404
+//     POST /v1/users @user.json
+// ELSE IF ${status} == 200
+//     ECHO the user exists already
+// ELSE
+//     ECHO something went wrong
+// END
+
+func processIf(opener statement, body []statement, _ statement) {
+	_, condition := split(opener.text)
+	comment(echoIfCommand, "IF: %s", condition)
+
+	for index, branch := range splitBranches(body) {
+		if index > 0 {
+			// the branch starts with ELSE [IF condition]
+			locate(branch[0])
+			_, remainder := split(branch[0].text)
+			keyword, alternative := split(remainder)
+			switch {
+			case len(remainder) == 0:
+				debug("ELSE branch taken")
+				runStatements(branch[1:])
+				return
+			case lower(keyword) == "if" && len(alternative) > 0:
+				condition = alternative
+				branch = branch[1:]
+			default:
+				quit("wrong ELSE [%s]", branch[0].text)
+			}
+		}
+
+		if passed, description := evaluateCondition(condition); passed {
+			debug("IF condition holds: %s", description)
+			runStatements(branch)
+			return
+		} else {
+			debug("IF condition does not hold: %s", description)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+)
+
+// INCLUDE common/login.gurl
+//   runs the script (relative to the current one) in the same context
+
+type frame struct {
+	file   string
+	line   int
+	reason string
+}
+
+var (
+	callStack = []frame{}
+)
+
+func processInclude(params, options string) {
+	comment(echoIncludeCommand, "INCLUDE: %s", params)
+
+	name := expand(params)
+	if len(name) == 0 {
+		quit("INCLUDE requires a file name")
+	}
+	fullname, err := expandPath(name)
+	quitOnError(err, "Failed to process file [%s]", name)
+	if !filepath.IsAbs(fullname) && len(currentFile) > 0 {
+		fullname = filepath.Join(filepath.Dir(currentFile), fullname)
+	}
+
+	data, err := ioutil.ReadFile(fullname)
+	quitOnError(err, "Opening included file [%s]", fullname)
+
+	enter("included")
+	defer leave()
+
+	runStatements(parseScript(string(data), fullname))
+}
+
+// enter remembers the current location before the control goes elsewhere
+func enter(reason string) {
+	if len(callStack) >= callDepthLimit {
+		quit("too many nested INCLUDEs/CALLs (%v)", len(callStack))
+	}
+	callStack = append(callStack, frame{file: currentFile, line: currentLineNumber, reason: reason})
+}
+
+// leave restores the location, which was current when enter was called
+func leave() {
+	last := callStack[len(callStack)-1]
+	callStack = callStack[:len(callStack)-1]
+	currentFile = last.file
+	currentLineNumber = last.line
+}
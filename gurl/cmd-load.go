@@ -5,52 +5,70 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
-	"io/ioutil"
 	"os/user"
 	"path/filepath"
 	"strings"
 )
 
-// Require ${response:status} HEALTHY
+// LOAD token ./secrets.json json auth/token
+// LOAD port ./config.yaml yaml server/port
+// LOAD users ./users.csv csv					(no key: the whole content, e.g. for FOREACH user IN ${users})
+// LOAD * ./fixture.env env						(every top level key becomes a variable)
+// LOAD * ./fixture.hcl hcl fixture.			(... with the given prefix: ${fixture.name})
+//
+// the formats: json, yaml (yml), hcl, env (dotenv), csv (the rows become objects keyed by the header row)
 
 func processLoad(params, options string) {
 	comment(echoLoadCommand, "LOAD: %s", params)
 
-	parts := strings.Split(params, " ")
-	if len(parts) >= 3 {
-		entry := parts[0]
-		filename := parts[1]
-		format := lower(parts[2])
-		key := parts[3]
-
-		fullfilename, err := expandPath(filename)
-		quitOnError(err, "Failed to process file [%s]", filename)
-
-		if format != "json" {
-			quit("LOAD command has wrong format [%s]", format)
-		}
-
-		data, err := ioutil.ReadFile(fullfilename)
-		quitOnError(err, "reading file [%s]", filename)
+	parts := strings.Fields(params)
+	if len(parts) < 3 || len(parts) > 4 {
+		quit("LOAD command has wrong arguments [%s], expected: LOAD name file format [key]", params)
+	}
+	entry, filename, format := parts[0], parts[1], lower(parts[2])
+	key := ""
+	if len(parts) > 3 {
+		key = parts[3]
+	}
 
-		var receiver interface{}
-		err = json.Unmarshal(data, &receiver)
-		quitOnError(err, "parsing content of file [%s]", filename)
+	fullfilename, err := expandPath(expand(filename))
+	quitOnError(err, "Failed to process file [%s]", filename)
+	content := loadDocument(fullfilename, format)
 
-		if success, value := resolveAny(receiver, key); success {
+	if entry == includeAllKey {
+		// the key (if any) is the prefix
+		loadAll(content, key, filename)
+		return
+	}
 
-			value = expand(value)
-			resolver.Add(entry, value)
+	if len(key) == 0 {
+		setVariable(entry, valueToText(content))
+		return
+	}
+	if success, value := lookupAny(content, key); success && value != nil {
+		setVariable(entry, expand(valueToText(value)))
+	} else {
+		quit("Cannot resolve key [%s] inside of the content of file [%s]", key, filename)
+	}
+}
 
-		} else {
-			quit("Cannot resolve key [%s] inside of the content of file [%s]", key, filename)
+// loadAll sets a variable per top level key (per column of the first row for csv)
+func loadAll(content interface{}, prefix, filename string) {
+	if rows, ok := content.(slice); ok {
+		if len(rows) == 0 {
+			quit("There is nothing to load in file [%s]", filename)
 		}
-
-	} else {
-		quit("LOAD command has wrong arguments [%s]", params)
+		content = rows[0]
+	}
+	values, ok := content.(msi)
+	if !ok {
+		quit("The content of file [%s] has no top level keys", filename)
+	}
+	for key, value := range values {
+		setVariable(prefix+key, expand(valueToText(value)))
 	}
+	debug("loaded %v variables from [%s]", len(values), filename)
 }
 
 // Dir returns the home directory for the executing user.
@@ -8,6 +8,11 @@ import "net/url"
 
 func processMap(params, options string) {
 	comment(echoMapCommand, "MAP command: %s", params)
+	if exporting {
+		if key, raw := split(params); len(options) == 0 && exportMap(expand(key), raw) {
+			return
+		}
+	}
 	key, value := split(expand(params))
 
 	if len(options) > 0 {
@@ -18,7 +23,7 @@ func processMap(params, options string) {
 		}
 	}
 
-	resolver.Add(key, value)
+	setVariable(key, value)
 
 	if offline() {
 		generate("%s=%s", key, value)
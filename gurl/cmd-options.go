@@ -0,0 +1,10 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+func processOptions(params, options string) {
+	comment(echoOptionsCommand, "OPTIONS command: %s", params)
+	call(params, "OPTIONS", "", options)
+}
@@ -6,6 +6,6 @@ package main
 
 func processPatch(params, options string) {
 	comment(echoPatchCommand, "PATCH command: %s", params)
-	relativeUrl, payload := split(expand(params))
-	call(relativeUrl, "PATCH", payload)
+	relativeUrl, payload := split(params)
+	call(relativeUrl, "PATCH", payload, options)
 }
@@ -0,0 +1,138 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// POST /jobs @job.json
+// MAP job ${response:id}
+//
+// POLL attempts=20 timeout=2m delay=500ms backoff=exponential max.delay=10s
+//     GET /jobs/${job}
+// UNTIL ${response:status} == DONE
+
+type pollSettings struct {
+	attempts    int
+	timeout     time.Duration
+	delay       time.Duration
+	maxDelay    time.Duration
+	exponential bool
+}
+
+func processPoll(opener statement, body []statement, closer statement) {
+	_, params := split(opener.text)
+	_, condition := split(closer.text)
+	comment(echoPollCommand, "POLL: %s, until: %s", params, condition)
+
+	if len(condition) == 0 {
+		quit("UNTIL requires a condition")
+	}
+	settings := parsePollSettings(params)
+
+	started := time.Now()
+	delay := settings.delay
+	description := ""
+	attempt := 1
+	for ; ; attempt++ {
+		runStatements(body)
+
+		locate(closer)
+		var passed bool
+		if passed, description = evaluateCondition(condition); passed {
+			comment(echoProgress, "POLL succeeded after %v attempt(s): %s", attempt, description)
+			checkPassed()
+			return
+		}
+		debug("POLL attempt %v: %s", attempt, description)
+
+		if offline() || attempt >= settings.attempts {
+			break
+		}
+		if settings.timeout > 0 && time.Since(started)+delay > settings.timeout {
+			break
+		}
+
+		time.Sleep(delay)
+		if settings.exponential {
+			delay *= 2
+			if delay > settings.maxDelay {
+				delay = settings.maxDelay
+			}
+		}
+	}
+
+	if offline() {
+		return
+	}
+	displayLastExchange()
+	checkFailed("POLL condition did not hold after %v attempt(s) in %s: %s", attempt, time.Since(started).Round(time.Millisecond), description)
+}
+
+func parsePollSettings(params string) pollSettings {
+	settings := pollSettings{
+		attempts: pollAttemptsDefault,
+		delay:    pollDelayDefault,
+		maxDelay: pollMaxDelayDefault,
+	}
+
+	maxDelayGiven := false
+	for _, option := range strings.Fields(expand(params)) {
+		key, value := splitBy(option, "=")
+		var err error
+		switch lower(key) {
+		case "attempts":
+			settings.attempts, err = strconv.Atoi(value)
+		case "timeout":
+			settings.timeout, err = time.ParseDuration(value)
+		case "delay":
+			settings.delay, err = time.ParseDuration(value)
+		case "max.delay":
+			settings.maxDelay, err = time.ParseDuration(value)
+			maxDelayGiven = true
+		case "backoff":
+			switch lower(value) {
+			case "fixed":
+				settings.exponential = false
+			case "exponential":
+				settings.exponential = true
+			default:
+				quit("unknown POLL backoff [%s], expected: fixed or exponential", value)
+			}
+		default:
+			quit("unknown POLL option [%s]", option)
+		}
+		quitOnError(err, "Parsing POLL option [%s]", option)
+	}
+
+	if settings.attempts <= 0 {
+		quit("POLL requires a positive number of attempts")
+	}
+	if settings.maxDelay < settings.delay {
+		if maxDelayGiven {
+			quit("POLL max.delay (%s) cannot be shorter than its delay (%s)", settings.maxDelay, settings.delay)
+		}
+		// the delay is never cut down by the default cap
+		settings.maxDelay = settings.delay
+	}
+	return settings
+}
+
+// displayLastExchange shows the (beginning of the) last response
+func displayLastExchange() {
+	if lastExchange == nil {
+		responseFailure("There was no response yet.")
+		return
+	}
+	responseFailure("Last response: %s %s -> %s", lastExchange.method, lastExchange.url, lastExchange.statusText)
+	body := string(lastExchange.body)
+	if len(body) > pollBodyPreviewSize {
+		body = body[:pollBodyPreviewSize] + "..."
+	}
+	displayPlainBody([]byte(body), responseFailure)
+}
@@ -6,6 +6,6 @@ package main
 
 func processPost(params, options string) {
 	comment(echoPostCommand, "POST command: %s", params)
-	relativeUrl, payload := split(expand(params))
-	call(relativeUrl, "POST", payload)
+	relativeUrl, payload := split(params)
+	call(relativeUrl, "POST", payload, options)
 }
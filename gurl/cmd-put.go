@@ -0,0 +1,11 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+func processPut(params, options string) {
+	comment(echoPutCommand, "PUT command: %s", params)
+	relativeUrl, payload := split(params)
+	call(relativeUrl, "PUT", payload, options)
+}
@@ -0,0 +1,37 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "strings"
+
+// REQUEST PURGE /v1/cache/${entity}
+// REQUEST PROPFIND /dav/folder @propfind.xml
+
+func processRequest(params, options string) {
+	comment(echoRequestCommand, "REQUEST command: %s", params)
+	verb, remainder := split(params)
+	verb = expand(verb)
+	if !validVerb(verb) {
+		quit("REQUEST command has invalid verb [%s]", verb)
+	}
+	relativeUrl, payload := split(remainder)
+	call(relativeUrl, strings.ToUpper(verb), payload, options)
+}
+
+// validVerb checks that the verb is a legit http token (see RFC 7230, section 3.2.6)
+func validVerb(verb string) bool {
+	if len(verb) == 0 {
+		return false
+	}
+	for _, r := range verb {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -5,33 +5,27 @@
 package main
 
 // Require ${response:status} HEALTHY
+// Require ${response:count} >= 3
+// Require ${response:id} =~ ^[a-z0-9]+$
+// Require ${response:items} is-array
+// Require ${response:items} length == 3
+// Require ${response:error} absent
 
 func processRequire(params, options string) {
+	if exporting {
+		exportRequire(params)
+	}
 	if offline() {
 		debug("REQUIRE has no effect in offline mode.")
 		return
 	}
 	comment(echoRequireCommand, "REQUIRE: %s", params)
 
-	left, right := split(params)
-	eleft := expand(left)
-	eright := expand(right)
-
-	// handle special case here, when mere existence was required
-	if len(right) == 0 {
-		if len(left) != 0 && len(eleft) == 0 {
-			quit("failed required condition: [%s] is not empty", left)
-		}
-		comment(echoProgress, "Require passed: [%s] is not empty", left)
+	passed, description := evaluateCondition(params)
+	if !passed {
+		checkFailed("failed required condition: %s", description)
 		return
 	}
-
-	if eleft != eright {
-		if lower(eleft) != lower(eright) {
-			quit("failed required condition: [%s] != [%s]", eleft, eright)
-		} else {
-			debug("Require command succeeded only in case-insensitive comparison. [%s] and [%s]", left, right)
-		}
-	}
-	comment(echoProgress, "Require passed: [%s] == [%s]", left, right)
+	comment(echoProgress, "Require passed: %s", description)
+	checkPassed()
 }
@@ -7,6 +7,13 @@ package main
 func processSet(params, options string) {
 	comment(echoSetCommand, "SET command: %s", params)
 	key, value := split(expand(params))
+	setting := key
+	if lower(key) == "baseurl" {
+		setting = "base.url"
+	}
+	if isOverridden(setting) {
+		return
+	}
 
 	for name, dial := range dials {
 		if lower(key) == name {
@@ -14,10 +21,15 @@ func processSet(params, options string) {
 			return
 		}
 	}
+	if setKnob(key, value) {
+		return
+	}
 
 	switch lower(key) {
 	case "baseurl":
 		baseUrl = value
+	case "openapi.spec":
+		useApiSpec(value)
 
 	/*
 		case "producecurl":
@@ -0,0 +1,41 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "strconv"
+
+// WHILE ${response:next} exists
+//     GET ${response:next}
+// END
+//
+// WHILE:20 ${counter} < 5	- at most 20 iterations (the default is 100)
+
+func processWhile(opener statement, body []statement, _ statement) {
+	cmd, condition := split(opener.text)
+	_, options := splitBy(cmd, ":")
+	comment(echoWhileCommand, "WHILE: %s", condition)
+
+	limit := whileIterationsLimit
+	if len(options) > 0 {
+		value, err := strconv.Atoi(options)
+		if err != nil || value <= 0 {
+			quit("WHILE has wrong iterations limit [%s]", options)
+		}
+		limit = value
+	}
+
+	for iteration := 0; ; iteration++ {
+		locate(opener)
+		passed, description := evaluateCondition(condition)
+		if !passed {
+			debug("WHILE condition does not hold: %s", description)
+			return
+		}
+		if iteration >= limit {
+			quit("WHILE loop exceeded the limit of %v iterations", limit)
+		}
+		runStatements(body)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Conditions have one of the following forms:
+//	<left> <right>					- (legacy) equality, with case-insensitive fallback
+//	<left>							- (legacy) left is not empty
+//	<left> <operator> <right>		- see conditionOperators
+//	<left> <check>					- see conditionChecks
+//	<left> length <operator> <n>	- length of an array, object or string
+
+type (
+	operand struct {
+		raw   string
+		found bool
+		value interface{}
+		text  string
+	}
+
+	checkFunc func(what operand) bool
+)
+
+var conditionChecks = map[string]checkFunc{
+	"exists":     func(what operand) bool { return what.found },
+	"absent":     func(what operand) bool { return !what.found },
+	"empty":      func(what operand) bool { return what.found && length(what.value) == 0 },
+	"not-empty":  func(what operand) bool { return what.found && length(what.value) > 0 },
+	"is-number":  func(what operand) bool { return what.found && isType(what.value, "number") },
+	"is-string":  func(what operand) bool { return what.found && isType(what.value, "string") },
+	"is-bool":    func(what operand) bool { return what.found && isType(what.value, "bool") },
+	"is-boolean": func(what operand) bool { return what.found && isType(what.value, "bool") },
+	"is-array":   func(what operand) bool { return what.found && isType(what.value, "array") },
+	"is-object":  func(what operand) bool { return what.found && isType(what.value, "object") },
+	"is-null":    func(what operand) bool { return what.found && what.value == nil },
+}
+
+var singleReference = regexp.MustCompile(`^\$\{([^{}]+)\}$`)
+
+// evaluateCondition returns the outcome of the condition and its human-readable description
+func evaluateCondition(params string) (bool, string) {
+	left, rest := split(params)
+	operator, right := split(rest)
+	operator = lower(operator)
+
+	if evaluator, found := conditionOperators[operator]; found && len(right) > 0 {
+		what := resolveOperand(left)
+		expected := expand(right)
+		return what.found && evaluator(what.text, expected), fmt.Sprintf("[%s] %s [%s]", what.text, operator, expected)
+	}
+
+	if check, found := conditionChecks[operator]; found && len(right) == 0 {
+		what := resolveOperand(left)
+		return check(what), fmt.Sprintf("[%s] %s", left, operator)
+	}
+
+	if operator == "length" {
+		compare, expected := split(right)
+		if evaluator, found := conditionOperators[lower(compare)]; found && len(expected) > 0 {
+			what := resolveOperand(left)
+			size := strconv.Itoa(length(what.value))
+			expected = expand(expected)
+			return what.found && evaluator(size, expected), fmt.Sprintf("length of [%s] (%s) %s [%s]", left, size, compare, expected)
+		}
+		quit("wrong length condition [%s]", params)
+	}
+
+	// the legacy forms
+	eleft := expand(left)
+	if len(rest) == 0 {
+		if len(left) != 0 && len(eleft) == 0 {
+			return false, fmt.Sprintf("[%s] is not empty", left)
+		}
+		return true, fmt.Sprintf("[%s] is not empty", left)
+	}
+
+	eright := expand(rest)
+	if eleft != eright {
+		if lower(eleft) != lower(eright) {
+			return false, fmt.Sprintf("[%s] != [%s]", eleft, eright)
+		}
+		debug("Condition succeeded only in case-insensitive comparison. [%s] and [%s]", left, rest)
+	}
+	return true, fmt.Sprintf("[%s] == [%s]", left, rest)
+}
+
+// resolveOperand keeps the type of the value when the operand is a single reference (e.g. ${response:items})
+func resolveOperand(raw string) operand {
+	if match := singleReference.FindStringSubmatch(raw); match != nil {
+		if found, value := typedValue(match[1]); found {
+			return operand{raw: raw, found: true, value: value, text: valueToText(value)}
+		} else if isTypedReference(match[1]) {
+			return operand{raw: raw}
+		}
+	}
+
+	text := expand(raw)
+	return operand{raw: raw, found: len(text) > 0, value: inferValue(text), text: text}
+}
+
+// inferValue attempts to figure out the type of the value from its text
+func inferValue(text string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err == nil {
+		return value
+	}
+	return text
+}
+
+func isType(value interface{}, kind string) bool {
+	switch value.(type) {
+	case float64, int, int64:
+		return kind == "number"
+	case string:
+		return kind == "string"
+	case bool:
+		return kind == "bool"
+	case slice:
+		return kind == "array"
+	case msi:
+		return kind == "object"
+	}
+	return false
+}
+
+func length(value interface{}) int {
+	switch actual := value.(type) {
+	case string:
+		return utf8.RuneCountInString(actual)
+	case slice:
+		return len(actual)
+	case msi:
+		return len(actual)
+	case nil:
+		return 0
+	}
+	return len(valueToText(value))
+}
@@ -4,12 +4,17 @@
 
 package main
 
-import "github.com/fatih/color"
+import (
+	"time"
+
+	"github.com/fatih/color"
+)
 
 const (
 	exitCodeOnError   = 7
 	exitCodeOnUsage   = 3
-	exitCodeOnSuccess = 1
+	exitCodeOnSuccess = 0
+	exitCodeOnFailure = 1
 
 	lineSeparator  = "\n"
 	wordSeparator  = " \t"
@@ -19,16 +24,22 @@ const (
 	leadingWhiteSpace  = " \t"
 	trainingWhiteSpace = " \t\r\n"
 
-	commentPrefix             = "#"
-	userAgent                 = "seamia/gurl"
-	envDefaultsLocation       = "GURL_DEFAULT_SETTINGS"
-	configurationHeaderPrefix = "header:"
-	externalFilePrefix        = "@"
+	commentPrefix               = "#"
+	userAgent                   = "seamia/gurl"
+	envDefaultsLocation         = "GURL_DEFAULT_SETTINGS"
+	configurationHeaderPrefix   = "header:"
+	configurationVariablePrefix = "variable:"
+	externalFilePrefix          = "@"
 
 	printResponseHeadersDefault = true
+	printResponseBodyDefault    = true
 	generateCurlCommandsDefault = false
 	collectTimingInfoDefault    = false
 	resolveExternalFilesDefault = true
+	skipTlsVerifyDefault        = false
+	followRedirectsDefault      = true
+	enableHttp2Default          = true
+	useCookieJarDefault         = true
 
 	colorComment           = color.FgGreen
 	colorError             = color.FgRed
@@ -40,7 +51,9 @@ const (
 	colorResponseAttention = color.FgYellow
 
 	headerContentType     = "Content-Type"
+	headerAuthorization   = "Authorization"
 	contentTypeJson       = "application/json"
+	contentTypeForm       = "application/x-www-form-urlencoded"
 	headerAttentionSuffix = "-error"
 
 	responsePrettyPrintBodyDefault = true
@@ -57,6 +70,29 @@ const (
 
 	mappingResponseValues = "response:"
 
+	reportFormatJUnit = "junit"
+	reportFormatTap   = "tap"
+
+	whileIterationsLimit = 100
+	callDepthLimit       = 64
+
+	pollAttemptsDefault = 10
+	pollDelayDefault    = time.Second
+	pollMaxDelayDefault = 30 * time.Second
+	pollBodyPreviewSize = 1024
+
+	downloadProgressInterval = 500 * time.Millisecond
+	binarySniffSize          = 512
+	hexdumpPreviewSize       = 256
+
+	oauth2TokenLifetimeDefault = 5 * time.Minute
+	oauth2ExpirySkew           = 30 * time.Second
+	hmacCanonicalDefault       = `{method}\n{path}\n{timestamp}\n{body.sha256}`
+
+	maxRefDepth          = 32
+	exampleDepthLimit    = 6
+	generatedPlaceholder = "TODO"
+
 	echoDefault  = true
 	indexInvalid = -1
 )
@@ -0,0 +1,105 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// trackingJar remembers the urls (and the attributes of the cookies) it has seen,
+// since the standard jar does not allow to enumerate its content
+type trackingJar struct {
+	*cookiejar.Jar
+	urls map[string]*url.URL
+	// the cookies as they were set, by the url of their path and their name
+	attributes map[string]*http.Cookie
+}
+
+var (
+	cookieJar = newCookieJar()
+)
+
+func newCookieJar() *trackingJar {
+	jar, _ := cookiejar.New(nil) // the error is always nil
+	return &trackingJar{Jar: jar, urls: make(map[string]*url.URL), attributes: make(map[string]*http.Cookie)}
+}
+
+func (jar *trackingJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	for _, cookie := range cookies {
+		path := cookie.Path
+		if len(path) == 0 || path[0] != '/' {
+			path = defaultCookiePath(u.Path)
+		}
+		target := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: path}
+		key := target.String() + " " + cookie.Name
+		if cookie.MaxAge < 0 || (!cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())) {
+			delete(jar.attributes, key)
+			continue
+		}
+		jar.urls[target.String()] = target
+		kept := *cookie
+		kept.Path = path
+		jar.attributes[key] = &kept
+	}
+	jar.Jar.SetCookies(u, cookies)
+}
+
+// defaultCookiePath is the path of a cookie set without one (rfc 6265, 5.1.4)
+func defaultCookiePath(path string) string {
+	last := strings.LastIndex(path, "/")
+	if last <= 0 {
+		return "/"
+	}
+	return path[:last]
+}
+
+// all returns the cookies (with their attributes) by the url of their path
+func (jar *trackingJar) all() map[string][]*http.Cookie {
+	result := make(map[string][]*http.Cookie)
+	for name, u := range jar.urls {
+		seen := map[string]bool{}
+		// the jar lists the more specific paths first, the ones of the parent paths are listed under their own
+		for _, cookie := range jar.Cookies(u) {
+			set, found := jar.attributes[name+" "+cookie.Name]
+			if !found || seen[cookie.Name] {
+				seen[cookie.Name] = true
+				continue
+			}
+			seen[cookie.Name] = true
+			kept := *set
+			kept.Value = cookie.Value
+			result[name] = append(result[name], &kept)
+		}
+	}
+	return result
+}
+
+func (jar *trackingJar) sortedUrls() []string {
+	names := make([]string, 0, len(jar.urls))
+	for name := range jar.urls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ${cookie:sid} - the value of the cookie that would be sent to the base url
+func cookieValue(_ *exchange, name string) (bool, interface{}) {
+	u, err := url.Parse(expand(baseUrl))
+	if err != nil || len(name) == 0 {
+		return false, nil
+	}
+	for _, cookie := range cookieJar.Cookies(u) {
+		if cookie.Name == name {
+			return true, cookie.Value
+		}
+	}
+	return false, nil
+}
@@ -5,10 +5,12 @@
 package main
 
 import (
+	"fmt"
+	"net/http"
 	"strings"
 )
 
-func produceCurlCommand(fullUrl, verb, data string) {
+func produceCurlCommand(fullUrl, verb, data string, form *formBody, extra http.Header, output string) {
 	printer := generate
 
 	printer("# %s %s", verb, fullUrl)
@@ -16,17 +18,48 @@ func produceCurlCommand(fullUrl, verb, data string) {
 	if len(curlOptions) > 0 {
 		printer("  %s \\", curlOptions)
 	}
-	printer("  --request %s \\", strings.ToUpper(verb))
+	for _, option := range curlClientOptions() {
+		printer("  %s \\", option)
+	}
+	if strings.ToUpper(verb) == "HEAD" {
+		// "--request HEAD" makes curl wait for a body that never arrives
+		printer("  --head \\")
+	} else {
+		printer("  --request %s \\", strings.ToUpper(verb))
+	}
 	printer("  --url %s \\", fullUrl)
+	if len(output) > 0 {
+		printer("  --output %s --create-dirs \\", output)
+	}
 
 	for key, value := range headers {
+		if _, overridden := extra[http.CanonicalHeaderKey(key)]; overridden {
+			// the authentication replaces the header set by the script
+			continue
+		}
+		if form != nil && http.CanonicalHeaderKey(key) == headerContentType {
+			// curl sets it (with the boundary) for the form
+			continue
+		}
 		if len(key) > 0 && len(value) > 0 {
 			//   --header 'origin: ${value}'   \
 			printer("   --header '%s: %s'   \\", key, value)
 		}
 	}
+	for key := range extra {
+		printer("   --header '%s: %s'   \\", key, extra.Get(key))
+	}
 
-	if len(data) > 0 {
+	if form != nil {
+		arguments := form.curlArguments()
+		for at, argument := range arguments {
+			continuation := ""
+			if at < len(arguments)-1 {
+				continuation = " \\"
+			}
+			printer("   %s '%s'%s", argument[0], argument[1], continuation)
+		}
+	} else if len(data) > 0 {
 		external, filename := dataPointsToExternalFile(data)
 		if external {
 			printer("   --data-binary \"@%s\"", filename)
@@ -36,3 +69,293 @@ func produceCurlCommand(fullUrl, verb, data string) {
 	}
 	printer("")
 }
+
+// the other way: curl command lines (e.g. "Copy as cURL" of the browsers) into a script
+
+var curlFlagsWithValue = map[string]string{
+	"-X": "--request", "-H": "--header", "-d": "--data", "-F": "--form", "-u": "--user",
+	"-A": "--user-agent", "-e": "--referer", "-b": "--cookie", "-o": "--output", "-x": "--proxy",
+	"-m": "--max-time", "-E": "--cert",
+}
+
+var curlIgnoredFlags = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true, "-v": true, "--verbose": true,
+	"-i": true, "--include": true, "-L": true, "--location": true, "--compressed": true,
+	"-g": true, "--globoff": true, "-f": true, "--fail": true, "-N": true, "--no-buffer": true,
+	"--http1.1": true, "--http2": true, "-#": true, "--progress-bar": true,
+}
+
+func importCurl(data []byte) *importedScript {
+	script := &importedScript{source: "curl command line(s)"}
+	settings := map[string]bool{}
+	for _, words := range shellCommands(string(data)) {
+		if len(words) == 0 || words[0] != "curl" {
+			continue
+		}
+		request, extra := importCurlCommand(words[1:])
+		for _, setting := range extra {
+			if !settings[setting] {
+				settings[setting] = true
+				script.settings = append(script.settings, setting)
+			}
+		}
+		script.requests = append(script.requests, request)
+	}
+	if len(script.requests) == 0 {
+		quit("cannot find a curl command in [%s]", filename())
+	}
+	return script
+}
+
+func importCurlCommand(args []string) (*importedRequest, []string) {
+	request := &importedRequest{}
+	settings := []string{}
+	data := []string{}
+	method, query := "", false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			request.url = arg
+			continue
+		}
+
+		// -XPOST, -H'name: value', --header=value
+		flag, value, hasValue := arg, "", false
+		if long, found := curlFlagsWithValue[arg[:2]]; found && len(arg) > 2 && !strings.HasPrefix(arg, "--") {
+			flag, value, hasValue = long, arg[2:], true
+		} else if equal := strings.Index(arg, "="); strings.HasPrefix(arg, "--") && equal > 0 {
+			flag, value, hasValue = arg[:equal], arg[equal+1:], true
+		} else if long, found := curlFlagsWithValue[arg]; found {
+			flag = long
+		}
+		next := func() string {
+			if hasValue {
+				return value
+			}
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			request.note("the option %s has no value", flag)
+			return ""
+		}
+
+		switch flag {
+		case "--url":
+			request.url = next()
+		case "--request":
+			method = strings.ToUpper(next())
+		case "--header":
+			name, value := splitBy(next(), ":")
+			if len(value) > 0 {
+				request.headers = append(request.headers, [2]string{name, value})
+			}
+		case "--user-agent":
+			request.headers = append(request.headers, [2]string{"User-Agent", next()})
+		case "--referer":
+			request.headers = append(request.headers, [2]string{"Referer", next()})
+		case "--cookie":
+			if cookie := next(); strings.Contains(cookie, "=") {
+				request.headers = append(request.headers, [2]string{"Cookie", cookie})
+			} else {
+				request.note("the cookie file [%s]", cookie)
+			}
+		case "--data", "--data-ascii", "--data-binary", "--data-raw":
+			data = append(data, next())
+		case "--json":
+			data = append(data, next())
+			request.headers = append(request.headers, [2]string{headerContentType, contentTypeJson}, [2]string{"Accept", contentTypeJson})
+		case "--data-urlencode":
+			if request.form == nil {
+				request.form = &formBody{}
+			}
+			field := next()
+			switch name, value := splitBy(field, "="); {
+			case strings.Contains(field, "=") && len(name) > 0:
+				request.form.fields = append(request.form.fields, formField{name: name, value: value})
+			case strings.Contains(field, "@") && !strings.Contains(field, "="):
+				at := strings.Index(field, "@")
+				request.form.fields = append(request.form.fields, formField{name: field[:at], value: field[at+1:], file: true})
+			default:
+				request.note("--data-urlencode [%s] without a name", field)
+			}
+		case "--form", "--form-string":
+			if request.form == nil || !request.form.multipart {
+				request.form = &formBody{multipart: true}
+			}
+			field := next()
+			name, value := splitBy(field, "=")
+			if flag == "--form" && strings.HasPrefix(value, "@") {
+				request.form.fields = append(request.form.fields, parseFormField(name+"="+value, true))
+			} else {
+				if flag == "--form" && strings.HasPrefix(value, "<") {
+					request.note("the content of the file [%s] as the value of [%s]", value[1:], name)
+				}
+				request.form.fields = append(request.form.fields, formField{name: name, value: value})
+			}
+		case "--user":
+			user, password := splitBy(next(), ":")
+			request.auth = fmt.Sprintf(`AUTH basic "%s" "%s"`, user, password)
+		case "--oauth2-bearer":
+			request.auth = fmt.Sprintf(`AUTH bearer "%s"`, next())
+		case "--head", "-I":
+			method = http.MethodHead
+		case "--get", "-G":
+			query = true
+		case "--insecure", "-k":
+			settings = append(settings, "SET insecure.skip.verify yes")
+		case "--proxy":
+			settings = append(settings, "SET http.proxy "+next())
+		case "--max-time":
+			settings = append(settings, "SET http.timeout "+next()+"s")
+		case "--output":
+			output := next()
+			request.note("the body is saved into [%s], use the option save=%s", output, output)
+		default:
+			if curlIgnoredFlags[flag] {
+				continue
+			}
+			if short := combinedFlags(arg); short != nil {
+				args = append(append(args[:i+1:i+1], short...), args[i+1:]...)
+				continue
+			}
+			if _, found := curlFlagsWithValue[flag]; found || hasValue {
+				next()
+			}
+			request.note("the curl option %s", arg)
+		}
+	}
+
+	switch {
+	case query && len(data) > 0:
+		separator := "?"
+		if strings.Contains(request.url, "?") {
+			separator = "&"
+		}
+		request.url += separator + strings.Join(data, "&")
+	case len(data) > 0:
+		request.body = strings.Join(data, "&")
+		if method == "" {
+			method = http.MethodPost
+		}
+	case request.form != nil && method == "":
+		method = http.MethodPost
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+	request.method = method
+	if request.form != nil {
+		request.headers = withoutHeader(request.headers, headerContentType)
+	}
+	if len(request.url) == 0 {
+		request.note("the curl command has no url")
+	} else if !strings.Contains(request.url, "://") {
+		request.url = "http://" + request.url
+	}
+	return request, settings
+}
+
+// combinedFlags breaks -sSL into -s -S -L (when all of them are known to take no value)
+func combinedFlags(arg string) []string {
+	if strings.HasPrefix(arg, "--") || len(arg) < 3 {
+		return nil
+	}
+	flags := []string{}
+	for _, r := range arg[1:] {
+		flag := "-" + string(r)
+		if !curlIgnoredFlags[flag] && flag != "-I" && flag != "-G" && flag != "-k" {
+			return nil
+		}
+		flags = append(flags, flag)
+	}
+	return flags
+}
+
+// shellCommands breaks the text into the commands (and these into the words) the way a shell would
+func shellCommands(src string) [][]string {
+	commands := [][]string{}
+	words := []string{}
+	current := strings.Builder{}
+	started := false
+
+	endWord := func() {
+		if started {
+			words = append(words, current.String())
+			current.Reset()
+			started = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			commands = append(commands, words)
+		}
+		words = []string{}
+	}
+
+	runes := []rune(src)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes):
+			i++
+			if runes[i] == '\r' && i+1 < len(runes) && runes[i+1] == '\n' {
+				i++
+			}
+			if runes[i] != '\n' {
+				current.WriteRune(runes[i])
+				started = true
+			}
+		case r == '\'':
+			started = true
+			for i++; i < len(runes) && runes[i] != '\''; i++ {
+				current.WriteRune(runes[i])
+			}
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '\'':
+			// ANSI-C quoting: $'...\n...'
+			started = true
+			for i += 2; i < len(runes) && runes[i] != '\''; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						current.WriteRune('\n')
+					case 't':
+						current.WriteRune('\t')
+					case 'r':
+						current.WriteRune('\r')
+					default:
+						current.WriteRune(runes[i])
+					}
+					continue
+				}
+				current.WriteRune(runes[i])
+			}
+		case r == '"':
+			started = true
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune(`"\$`+"`", runes[i+1]) {
+					i++
+				}
+				current.WriteRune(runes[i])
+			}
+		case r == '\n' || r == ';' || r == '&' || r == '|':
+			endCommand()
+		case r == ' ' || r == '\t' || r == '\r':
+			endWord()
+		case r == '#' && !started:
+			// a comment till the end of the line
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			endCommand()
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	endCommand()
+	return commands
+}
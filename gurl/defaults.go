@@ -33,7 +33,15 @@ func loadDefaults() {
 	setResolverFilters()
 	processCmdLine()
 
-	// attempt to locate and load the defaults config file
+	// the settings come in layers: the defaults file, the environment, the secrets and the -D overrides
+	loadDefaultsFile()
+	loadEnvironment()
+	loadOverrides()
+	applySettings()
+}
+
+// loadDefaultsFile reads the (json) file named in GURL_DEFAULT_SETTINGS
+func loadDefaultsFile() {
 	location := os.Getenv(envDefaultsLocation)
 	location = expand(location)
 	if len(location) == 0 {
@@ -50,40 +58,53 @@ func loadDefaults() {
 	if err := json.Unmarshal(data, &settings); err != nil {
 		reportError(err, "Parsing content of [%s]", location)
 	}
+	addSettings(settings, location, false)
+	report("loaded default settings from %s", location)
+}
 
-	for key, value := range settings {
-		txt, _ := value.(string)
-		switch lower(key) {
-		case "base.url":
-			baseUrl = txt
-		case "curl.options":
-			curlOptions = txt
-		case "print.response.headers":
-			printResponseHeaders = getBoolean(txt, printResponseHeadersDefault)
-		case "generate.curl.commands":
-			debug("ignoring[%s]", key)
-			// generateCurlCommands = getBoolean(txt, generateCurlCommandsDefault)
-		case "collect.timing.info":
-			collectTimingInfo = getBoolean(txt, collectTimingInfoDefault)
-		case "color":
-			fmt.Println("setting color", getBoolean(txt, true))
-			color.NoColor = !getBoolean(txt, true)
-		default:
-			if strings.HasPrefix(lower(key), configurationHeaderPrefix) {
-				headerKey := key[len(configurationHeaderPrefix):]
-				headers[headerKey] = txt
+// applySetting returns false when the key is not a known setting
+func applySetting(key string, value interface{}) bool {
+	txt := valueToText(value)
+	switch lower(key) {
+	case "base.url":
+		baseUrl = txt
+	case "curl.options":
+		curlOptions = txt
+	case "print.response.headers":
+		printResponseHeaders = getBoolean(txt, printResponseHeadersDefault)
+	case "generate.curl.commands":
+		debug("ignoring[%s]", key)
+		// generateCurlCommands = getBoolean(txt, generateCurlCommandsDefault)
+	case "collect.timing.info":
+		collectTimingInfo = getBoolean(txt, collectTimingInfoDefault)
+	case "color":
+		fmt.Println("setting color", getBoolean(txt, true))
+		color.NoColor = !getBoolean(txt, true)
+	default:
+		if strings.HasPrefix(lower(key), configurationHeaderPrefix) {
+			headerKey := key[len(configurationHeaderPrefix):]
+			headers[headerKey] = txt
+		} else if strings.HasPrefix(lower(key), configurationVariablePrefix) {
+			resolver.Add(key[len(configurationVariablePrefix):], txt)
+		} else if dial, found := dials[lower(key)]; found {
+			if flag, converts := value.(bool); converts {
+				*dial = flag
+			} else {
+				*dial = getBoolean(txt, *dial)
 			}
+		} else if !setKnob(key, txt) {
+			return false
 		}
 	}
-	report("loaded default settings from %s", location)
+	return true
 }
 
 func processCmdLine() {
 	// generateCurlCommands
 
-	if len(os.Args) > 2 {
-		for i := 2; i < len(os.Args); i++ {
-			param := os.Args[i]
+	if len(cmdLineOptions) > 0 {
+		for i := 0; i < len(cmdLineOptions); i++ {
+			param := cmdLineOptions[i]
 			switch lower(param) {
 			case "-silent":
 				goSilent()
@@ -101,9 +122,78 @@ func processCmdLine() {
 				echoGetCommand = false
 				echoPostCommand = false
 				echoPatchCommand = false
+				echoPutCommand = false
 				echoDeleteCommand = false
+				echoHeadCommand = false
+				echoOptionsCommand = false
+				echoRequestCommand = false
 
 				debug("enabling curl commands generations")
+
+			case "-report":
+				if i+1 >= len(cmdLineOptions) {
+					quit("-report option requires a value (e.g. junit=report.xml or tap)")
+				}
+				i++
+				enableReport(cmdLineOptions[i])
+
+			case "-history":
+				if i+1 >= len(cmdLineOptions) {
+					quit("-history option requires a file name")
+				}
+				i++
+				enableHistoryDump(cmdLineOptions[i])
+
+			case "-session":
+				if i+1 >= len(cmdLineOptions) {
+					quit("-session option requires a file name")
+				}
+				i++
+				enableSession(cmdLineOptions[i])
+
+			case "-record":
+				if i+1 >= len(cmdLineOptions) {
+					quit("-record option requires a file name")
+				}
+				i++
+				enableRecording(cmdLineOptions[i])
+
+			case "-replay":
+				if i+1 >= len(cmdLineOptions) {
+					quit("-replay option requires a file name")
+				}
+				i++
+				enableReplay(cmdLineOptions[i])
+
+			case "-load":
+				if i+1 >= len(cmdLineOptions) {
+					quit("-load option requires a value (e.g. users=10,duration=30s)")
+				}
+				i++
+				enableLoadTest(cmdLineOptions[i])
+
+			case "-section":
+				if i+1 >= len(cmdLineOptions) {
+					quit("-section option requires a section name (or a pattern)")
+				}
+				i++
+				runSection = cmdLineOptions[i]
+
+			case "-parallel", "-run", "-tags":
+				// taken care of by runTests
+				i++
+
+			case "-env", "-secrets", "-d":
+				// taken care of by loadEnvironment and loadOverrides
+				i++
+
+			case "-load-worker":
+				if i+1 >= len(cmdLineOptions) {
+					quit("-load-worker option requires a value")
+				}
+				i++
+				enableLoadWorker(cmdLineOptions[i])
+
 			default:
 				debug("don't know how to handle param [%s]", param)
 			}
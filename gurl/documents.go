@@ -0,0 +1,187 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"gopkg.in/yaml.v3"
+)
+
+// the files LOAD (and the likes) read: whatever the format, the content comes out
+// the way encoding/json would have it (msi, slice, string, float64, bool, nil)
+
+type documentParser func(data []byte) (interface{}, error)
+
+var documentParsers = map[string]documentParser{
+	"json":   parseJsonDocument,
+	"yaml":   parseYamlDocument,
+	"yml":    parseYamlDocument,
+	"hcl":    parseHclDocument,
+	"env":    parseEnvDocument,
+	"dotenv": parseEnvDocument,
+	"csv":    parseCsvDocument,
+}
+
+func loadDocument(filename, format string) interface{} {
+	parse, found := documentParsers[lower(format)]
+	if !found {
+		quit("LOAD command has wrong format [%s], expected one of: json, yaml, hcl, env, csv", format)
+	}
+	data, err := ioutil.ReadFile(filename)
+	quitOnError(err, "reading file [%s]", filename)
+
+	content, err := parse(data)
+	quitOnError(err, "parsing content of file [%s]", filename)
+	return content
+}
+
+func parseJsonDocument(data []byte) (interface{}, error) {
+	var content interface{}
+	err := json.Unmarshal(data, &content)
+	return content, err
+}
+
+func parseYamlDocument(data []byte) (interface{}, error) {
+	var content interface{}
+	if err := yaml.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	return normalizeDocument(plainMaps(content))
+}
+
+func parseHclDocument(data []byte) (interface{}, error) {
+	var content interface{}
+	if err := hcl.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	return normalizeDocument(unwrapBlocks(content))
+}
+
+// unwrapBlocks undoes the lists hcl decodes every block into: server { port = 8080 }
+// comes out as server/port rather than server/0/port (the repeated blocks stay lists)
+func unwrapBlocks(content interface{}) interface{} {
+	switch actual := content.(type) {
+	case []map[string]interface{}:
+		if len(actual) == 1 {
+			return unwrapBlocks(actual[0])
+		}
+		list := make(slice, 0, len(actual))
+		for _, one := range actual {
+			list = append(list, unwrapBlocks(one))
+		}
+		return list
+	case slice:
+		if len(actual) == 1 {
+			if block, isMap := actual[0].(msi); isMap {
+				return unwrapBlocks(block)
+			}
+		}
+		for at, one := range actual {
+			actual[at] = unwrapBlocks(one)
+		}
+	case msi:
+		for key, one := range actual {
+			actual[key] = unwrapBlocks(one)
+		}
+	}
+	return content
+}
+
+// normalizeDocument turns the decoded content into its json counterpart (e.g. int into float64)
+func normalizeDocument(content interface{}) (interface{}, error) {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return parseJsonDocument(data)
+}
+
+// NAME=value, export NAME=value, NAME="with \"escapes\"\n", NAME='as is', # comments
+func parseEnvDocument(data []byte) (interface{}, error) {
+	content := msi{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, commentPrefix) {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		equal := strings.Index(line, "=")
+		if equal <= 0 {
+			return nil, fmt.Errorf("line %v: expected NAME=value, got [%s]", number, line)
+		}
+		name, value := strings.TrimSpace(line[:equal]), strings.TrimSpace(line[equal+1:])
+
+		switch {
+		case strings.HasPrefix(value, `"`):
+			end := closingQuote(value)
+			if end < 0 {
+				return nil, fmt.Errorf("line %v: unterminated quote", number)
+			}
+			unquoted, err := strconv.Unquote(value[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("line %v: %v", number, err)
+			}
+			value = unquoted
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %v: unterminated quote", number)
+			}
+			value = value[1 : end+1]
+		default:
+			if comment := strings.Index(value, " "+commentPrefix); comment >= 0 {
+				value = strings.TrimSpace(value[:comment])
+			}
+		}
+		content[name] = value
+	}
+	return content, scanner.Err()
+}
+
+// closingQuote returns the index of the (unescaped) double quote closing the value
+func closingQuote(value string) int {
+	for at := 1; at < len(value); at++ {
+		switch value[at] {
+		case '\\':
+			at++
+		case '"':
+			return at
+		}
+	}
+	return -1
+}
+
+// the rows become objects keyed by the header (the first row)
+func parseCsvDocument(data []byte) (interface{}, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return slice{}, err
+	}
+
+	header := records[0]
+	rows := make(slice, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := msi{}
+		for at, name := range header {
+			if at < len(record) {
+				row[strings.TrimSpace(name)] = record[at]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/seamia/libs/printer"
+)
+
+// GET:save=downloads/${name}.zip /files/${name}
+//   the body is streamed into the file (instead of being kept in memory and printed)
+
+type savedBody struct {
+	path   string
+	size   int64
+	sha256 string
+}
+
+// progress reports how the download goes (on stderr, so it does not end up in the redirected output)
+type progress struct {
+	total   int64
+	written int64
+	started time.Time
+	printed time.Time
+	visible bool
+}
+
+func (p *progress) Write(data []byte) (int, error) {
+	p.written += int64(len(data))
+	if time.Since(p.printed) >= downloadProgressInterval && time.Since(p.started) >= downloadProgressInterval {
+		p.show()
+	}
+	return len(data), nil
+}
+
+func (p *progress) show() {
+	if isSilent() || !echoProgress {
+		return
+	}
+	p.printed = time.Now()
+	p.visible = true
+	if p.total > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "\rdownloaded %s of %s (%v%%)   ", humanSize(p.written), humanSize(p.total), 100*p.written/p.total)
+	} else {
+		_, _ = fmt.Fprintf(os.Stderr, "\rdownloaded %s   ", humanSize(p.written))
+	}
+}
+
+func (p *progress) done() {
+	if p.visible {
+		p.show()
+		_, _ = fmt.Fprintln(os.Stderr)
+	}
+}
+
+func saveBody(resp *http.Response, target string) *savedBody {
+	if folder := filepath.Dir(target); len(folder) > 0 {
+		quitOnError(os.MkdirAll(folder, 0755), "Creating folder [%s]", folder)
+	}
+	file, err := os.Create(target)
+	quitOnError(err, "Creating file [%s]", target)
+	defer file.Close()
+
+	hash := sha256.New()
+	meter := &progress{total: resp.ContentLength, started: time.Now()}
+	size, err := io.Copy(io.MultiWriter(file, hash, meter), resp.Body)
+	meter.done()
+	quitOnError(err, "Saving response body into [%s]", target)
+
+	return &savedBody{path: target, size: size, sha256: hex.EncodeToString(hash.Sum(nil))}
+}
+
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%v B", size)
+	}
+	value, suffix := float64(size)/unit, "KMGTPE"
+	for value >= unit && len(suffix) > 1 {
+		value /= unit
+		suffix = suffix[1:]
+	}
+	return fmt.Sprintf("%.1f %siB", value, suffix[:1])
+}
+
+// isBinary decides whether the body can be shown on the terminal
+func isBinary(contentType string, data []byte) bool {
+	mediaType := lower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case len(mediaType) == 0:
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return false
+	}
+	for _, textual := range textualContentTypes {
+		if mediaType == textual {
+			return false
+		}
+	}
+	if len(mediaType) > 0 && mediaType != "application/octet-stream" {
+		return true
+	}
+
+	// no (useful) content type: look at the data itself
+	sample := data
+	if len(sample) > binarySniffSize {
+		sample = sample[:binarySniffSize]
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+	valid := utf8.Valid(sample)
+	for cut := 1; !valid && len(sample) < len(data) && cut < utf8.UTFMax; cut++ {
+		// the sample may end in the middle of a character
+		valid = utf8.Valid(sample[:len(sample)-cut])
+	}
+	return !valid
+}
+
+var textualContentTypes = []string{
+	contentTypeJson,
+	contentTypeForm,
+	"application/xml",
+	"application/javascript",
+	"application/x-javascript",
+	"application/yaml",
+	"application/x-yaml",
+	"application/graphql",
+	"application/x-ndjson",
+}
+
+func displayBinaryBody(data []byte, contentType string, print printer.Printer) {
+	hash := sha256.Sum256(data)
+	print("Body: %s of %s, sha256: %s", humanSize(int64(len(data))), orUnknown(contentType), hex.EncodeToString(hash[:]))
+
+	preview := data
+	if len(preview) > hexdumpPreviewSize {
+		preview = preview[:hexdumpPreviewSize]
+	}
+	if len(preview) > 0 {
+		print("%s", strings.TrimRight(hex.Dump(preview), lineSeparator))
+	}
+	if len(data) > len(preview) {
+		print("... (%v more bytes)", len(data)-len(preview))
+	}
+}
+
+func orUnknown(src string) string {
+	if len(src) == 0 {
+		return "unknown type"
+	}
+	return src
+}
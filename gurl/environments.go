@@ -0,0 +1,281 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// gurl -env staging script.gurl
+// gurl -env prod -D base.url=https://canary.example.com -D user=bob script.gurl
+// gurl settings -env staging			(prints the effective settings and where they come from)
+//
+// gurl.environments.yaml (next to the script, in the current directory, or named in GURL_ENVIRONMENTS):
+//
+// shared:								(applies to every environment)
+//   headers:
+//     Accept: application/json
+// local:
+//   base.url: http://localhost:8080
+//   variables:
+//     user: alice
+// staging:
+//   base.url: https://staging.example.com
+//   print.response.headers: false		(any setting of the defaults file)
+//
+// gurl.secrets.yaml (git-ignored, next to the environments file, or named with -secrets) has the same layout
+// and is merged on top of it. the values of -D are settings when known (base.url, header:X-Name, the dials
+// and the knobs), and variables otherwise.
+//
+// the precedence (lowest first): GURL_DEFAULT_SETTINGS, the environment, the secrets, the script, -D.
+// (MAP, SET and HEADER of a key given with -D are ignored)
+
+type settingValue struct {
+	key    string
+	value  interface{}
+	source string
+	secret bool
+}
+
+const (
+	environmentsFile       = "gurl.environments.yaml"
+	secretsFile            = "gurl.secrets.yaml"
+	envEnvironmentLocation = "GURL_ENVIRONMENTS"
+	envEnvironmentName     = "GURL_ENV"
+	sharedEnvironment      = "shared"
+	secretMask             = "******"
+)
+
+var (
+	// in the order they are applied
+	layeredSettings = []settingValue{}
+	// the environments file in use (if any)
+	environmentsLocation = ""
+	// the keys given with -D, which the script cannot change (MAP, SET, HEADER)
+	overrides = map[string]bool{}
+
+	// the sections of an environment that hold names rather than settings
+	environmentSections = map[string]string{
+		"headers":   configurationHeaderPrefix,
+		"variables": configurationVariablePrefix,
+	}
+)
+
+func addSettings(settings msi, source string, secret bool) {
+	for _, key := range sortedKeys(settings) {
+		layeredSettings = append(layeredSettings, settingValue{key: key, value: settings[key], source: source, secret: secret})
+	}
+}
+
+// loadEnvironment adds the shared and the selected parts of the environments and the secrets files
+func loadEnvironment() {
+	name, _ := optionValue("-env")
+	if len(name) == 0 {
+		name = os.Getenv(envEnvironmentName)
+	}
+
+	location := os.Getenv(envEnvironmentLocation)
+	if len(location) == 0 {
+		location = locateSettingsFile(environmentsFile)
+	}
+	if len(location) == 0 {
+		if len(name) > 0 {
+			quit("cannot find %s for the environment [%s]", environmentsFile, name)
+		}
+		return
+	}
+	environmentsLocation = location
+	environments := loadEnvironmentsFile(location)
+	if len(name) > 0 {
+		if _, found := environments[name]; !found || name == sharedEnvironment {
+			quit("there is no environment [%s] in %s, expected one of: %s", name, location, strings.Join(environmentNames(environments), ", "))
+		}
+		report("using environment %s from %s", name, location)
+	}
+	addEnvironment(environments, name, location, false)
+
+	secrets, _ := optionValue("-secrets")
+	if len(secrets) == 0 {
+		secrets = filepath.Join(filepath.Dir(location), secretsFile)
+		if _, err := os.Stat(secrets); err != nil {
+			return
+		}
+	}
+	addEnvironment(loadEnvironmentsFile(secrets), name, secrets, true)
+}
+
+// locateSettingsFile looks next to the script first, then in the current directory
+func locateSettingsFile(name string) string {
+	candidates := []string{name}
+	if len(filename()) > 0 {
+		candidates = append([]string{filepath.Join(filepath.Dir(filename()), name)}, candidates...)
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func loadEnvironmentsFile(location string) msi {
+	environments, ok := loadDocument(location, "yaml").(msi)
+	if !ok {
+		quit("expected the environments (as the top level keys) in %s", location)
+	}
+	return environments
+}
+
+func addEnvironment(environments msi, name, location string, secret bool) {
+	for _, one := range []string{sharedEnvironment, name} {
+		if settings, ok := environments[one].(msi); ok && len(one) > 0 {
+			addSettings(flattenEnvironment(settings, ""), fmt.Sprintf("%s: %s", location, one), secret)
+		}
+	}
+}
+
+// flattenEnvironment turns base: {url: x} into base.url: x, and headers: {X-Name: y} into header:X-Name: y
+func flattenEnvironment(settings msi, prefix string) msi {
+	flat := msi{}
+	for key, value := range settings {
+		nested, isMap := value.(msi)
+		if section, found := environmentSections[lower(key)]; found && isMap && len(prefix) == 0 {
+			for name, one := range nested {
+				flat[section+name] = one
+			}
+			continue
+		}
+		if isMap {
+			for name, one := range flattenEnvironment(nested, prefix+key+".") {
+				flat[name] = one
+			}
+			continue
+		}
+		flat[prefix+key] = value
+	}
+	return flat
+}
+
+func environmentNames(environments msi) []string {
+	names := []string{}
+	for _, name := range sortedKeys(environments) {
+		if name != sharedEnvironment {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// loadOverrides adds the -D key=value pairs
+func loadOverrides() {
+	for i := 0; i < len(cmdLineOptions); i++ {
+		if lower(cmdLineOptions[i]) != "-d" {
+			continue
+		}
+		if i+1 >= len(cmdLineOptions) {
+			quit("-D option requires a value (e.g. base.url=http://localhost:8080)")
+		}
+		i++
+		key, value := cmdLineOptions[i], ""
+		if equal := strings.Index(key, "="); equal > 0 {
+			key, value = key[:equal], key[equal+1:]
+		} else {
+			quit("-D option expects key=value, got [%s]", key)
+		}
+		if !isSetting(key) {
+			key = configurationVariablePrefix + key
+		}
+		layeredSettings = append(layeredSettings, settingValue{key: key, value: value, source: "-D"})
+		overrides[overrideKey(key)] = true
+	}
+}
+
+// overrideKey keeps the case of the variable names only
+func overrideKey(key string) string {
+	if strings.HasPrefix(lower(key), configurationVariablePrefix) {
+		return configurationVariablePrefix + key[len(configurationVariablePrefix):]
+	}
+	return lower(key)
+}
+
+// isOverridden tells whether the setting (or the variable) was given with -D, reporting the attempt to change it
+func isOverridden(key string) bool {
+	if overrides[overrideKey(key)] {
+		debug("keeping the -D value of [%s]", key)
+		return true
+	}
+	return false
+}
+
+func isSetting(key string) bool {
+	key = lower(key)
+	switch key {
+	case "base.url", "curl.options", "print.response.headers", "generate.curl.commands", "collect.timing.info", "color":
+		return true
+	}
+	if strings.HasPrefix(key, configurationHeaderPrefix) || strings.HasPrefix(key, configurationVariablePrefix) {
+		return true
+	}
+	if _, found := dials[key]; found {
+		return true
+	}
+	_, found := knobs[key]
+	return found
+}
+
+func applySettings() {
+	for _, setting := range layeredSettings {
+		if !applySetting(setting.key, setting.value) {
+			debug("ignoring unknown setting [%s] from %s", setting.key, setting.source)
+		}
+	}
+}
+
+// runSettings prints the effective settings, along with where they come from
+func runSettings() {
+	effective := map[string]settingValue{}
+	for key, dial := range dials {
+		effective[key] = settingValue{key: key, value: *dial, source: "default"}
+	}
+	for key, knob := range knobs {
+		if len(*knob) > 0 {
+			effective[key] = settingValue{key: key, value: *knob, source: "default"}
+		}
+	}
+	effective["base.url"] = settingValue{key: "base.url", value: baseUrl, source: "default"}
+	effective["curl.options"] = settingValue{key: "curl.options", value: curlOptions, source: "default"}
+	for _, setting := range layeredSettings {
+		if !isSetting(setting.key) {
+			setting.source += " (unknown, ignored)"
+		}
+		key := lower(setting.key)
+		if strings.HasPrefix(key, configurationHeaderPrefix) || strings.HasPrefix(key, configurationVariablePrefix) {
+			// the names keep their case
+			key = setting.key
+		}
+		if setting.secret {
+			setting.value = secretMask
+		}
+		effective[key] = setting
+	}
+
+	keys := make([]string, 0, len(effective))
+	width := 0
+	for key := range effective {
+		keys = append(keys, key)
+		if len(key) > width {
+			width = len(key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("%-*s  %-40s  (%s)\n", width, key, valueToText(effective[key].value), effective[key].source)
+	}
+	exit(exitCodeOnSuccess)
+}
@@ -4,7 +4,11 @@
 
 package main
 
-import "strings"
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
 
 type Evaluate func(left, right string) bool
 
@@ -32,6 +36,62 @@ func evalPrefix(left, right string) bool {
 	return strings.HasPrefix(left, right)
 }
 
+func evalEqualNumeric(left, right string) bool {
+	if l, r, numeric := numbers(left, right); numeric {
+		return l == r
+	}
+	return left == right
+}
+
+func evalNotEqualNumeric(left, right string) bool {
+	return !evalEqualNumeric(left, right)
+}
+
+func evalLess(left, right string) bool {
+	l, r, numeric := numbers(left, right)
+	return numeric && l < r
+}
+
+func evalLessOrEqual(left, right string) bool {
+	l, r, numeric := numbers(left, right)
+	return numeric && l <= r
+}
+
+func evalGreater(left, right string) bool {
+	l, r, numeric := numbers(left, right)
+	return numeric && l > r
+}
+
+func evalGreaterOrEqual(left, right string) bool {
+	l, r, numeric := numbers(left, right)
+	return numeric && l >= r
+}
+
+func evalMatch(left, right string) bool {
+	expression, err := regexp.Compile(right)
+	if err != nil {
+		reportError(err, "compiling regular expression [%s]", right)
+		return false
+	}
+	return expression.MatchString(left)
+}
+
+func evalNotMatch(left, right string) bool {
+	return !evalMatch(left, right)
+}
+
+func numbers(left, right string) (float64, float64, bool) {
+	l, err := strconv.ParseFloat(strings.TrimSpace(left), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	r, err := strconv.ParseFloat(strings.TrimSpace(right), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return l, r, true
+}
+
 func hasFieldValue(what interface{}, field, looking4 string, compare Evaluate) bool {
 	if object, converts := what.(msi); converts {
 		if data, exists := object[field]; exists {
@@ -57,6 +117,29 @@ var evaluateMap = map[string]Evaluate{
 	"=)": evalSuffix,
 }
 
+// binary operators available to REQUIRE (and other conditions)
+// note: unlike in the filters above, "<=" is a numeric comparison here
+var conditionOperators = map[string]Evaluate{
+	"==": evalEqualNumeric,
+	"!=": evalNotEqualNumeric,
+	"<":  evalLess,
+	"<=": evalLessOrEqual,
+	">":  evalGreater,
+	">=": evalGreaterOrEqual,
+	"=~": evalMatch,
+	"!~": evalNotMatch,
+
+	"contains":  evalContains,
+	"!contains": evalNotContains,
+	"prefix":    evalPrefix,
+	"suffix":    evalSuffix,
+	"matches":   evalMatch,
+
+	"=>": evalNotContains,
+	"=(": evalPrefix,
+	"=)": evalSuffix,
+}
+
 func findEvaluator(cmd string) (string, string, Evaluate) {
 	for key, evalFunc := range evaluateMap {
 		if index := strings.Index(cmd, key); index >= 0 {
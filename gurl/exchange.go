@@ -0,0 +1,92 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// exchange keeps everything we know about the last request/response
+type exchange struct {
+	label      string
+	method     string
+	url        string
+	status     int
+	statusText string
+	header     http.Header
+	body       []byte
+	timing     map[string]time.Duration
+}
+
+const (
+	timingDns     = "dns"
+	timingConnect = "connect"
+	timingTls     = "tls"
+	timingTtfb    = "ttfb"
+	timingTotal   = "total"
+)
+
+var (
+	lastExchange *exchange
+)
+
+// traceTiming attaches a tracer to the request, and gives the timing of the exchange collected so far.
+// the callbacks may run on the transport's goroutines (e.g. a connection dialed in the background),
+// hence the lock
+func traceTiming(request *http.Request) (*http.Request, func() map[string]time.Duration) {
+	var guard sync.Mutex
+	var start, dnsStart, connectStart, tlsStart time.Time
+	timing := make(map[string]time.Duration)
+
+	mark := func(moment *time.Time) {
+		guard.Lock()
+		*moment = time.Now()
+		guard.Unlock()
+	}
+	measure := func(phase string, since *time.Time) {
+		guard.Lock()
+		timing[phase] = time.Since(*since)
+		guard.Unlock()
+	}
+	trace := &httptrace.ClientTrace{
+		GetConn:              func(string) { mark(&start) },
+		DNSStart:             func(httptrace.DNSStartInfo) { mark(&dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { measure(timingDns, &dnsStart) },
+		ConnectStart:         func(string, string) { mark(&connectStart) },
+		ConnectDone:          func(string, string, error) { measure(timingConnect, &connectStart) },
+		TLSHandshakeStart:    func() { mark(&tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { measure(timingTls, &tlsStart) },
+		GotFirstResponseByte: func() { measure(timingTtfb, &start) },
+	}
+	collected := func() map[string]time.Duration {
+		guard.Lock()
+		defer guard.Unlock()
+		result := make(map[string]time.Duration, len(timing)+1)
+		for phase, duration := range timing {
+			result[phase] = duration
+		}
+		return result
+	}
+	return request.WithContext(httptrace.WithClientTrace(request.Context(), trace)), collected
+}
+
+func newExchange(request *http.Request, resp *http.Response, body []byte, timing map[string]time.Duration) *exchange {
+	result := &exchange{
+		method: request.Method,
+		url:    request.URL.String(),
+		body:   body,
+		timing: timing,
+	}
+	if resp != nil {
+		result.status = resp.StatusCode
+		result.statusText = resp.Status
+		result.header = resp.Header
+	}
+	return result
+}
@@ -0,0 +1,405 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gurl export --format=curl|httpie|http|postman [--output file] script.gurl
+//
+// the script runs offline: every request is recorded (with the variables and headers expanded
+// at the time it is made), a MAP of a response value becomes a capture of the request that
+// produced the value, and a REQUIRE becomes a check of the last request.
+// the values captured from the responses are referred to as {{name}} in the recorded requests.
+
+type (
+	exportedRequest struct {
+		name     string
+		label    string
+		method   string
+		url      string
+		headers  [][2]string
+		body     string
+		form     *formBody
+		captures []exportCapture
+		checks   []string
+	}
+
+	exportCapture struct {
+		variable  string
+		namespace string
+		param     string
+	}
+
+	exporter func(requests []*exportedRequest) []byte
+)
+
+var (
+	exporting = false
+	exported  = []*exportedRequest{}
+
+	exporters = map[string]exporter{
+		"curl":    exportCurl,
+		"httpie":  exportHttpie,
+		"http":    exportRestClient,
+		"postman": exportPostman,
+	}
+
+	placeholderPattern        = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+	escapedPlaceholderPattern = regexp.MustCompile(`%7B%7B([^%]+)%7D%7D`)
+	identifierPattern         = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	nonIdentifierPattern      = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+func runExport() {
+	format, _ := optionValue("--format", "-format")
+	export, found := exporters[lower(format)]
+	if !found {
+		quit("export requires --format=curl, httpie, http or postman, got [%s]", format)
+	}
+
+	exporting = true
+	goSilent()
+
+	data, err := ioutil.ReadFile(filename())
+	quitOnError(err, "Opening file %s", filename())
+	currentFile = filename()
+	processScript(string(data))
+
+	result := export(exported)
+	if output, found := optionValue("--output", "-output", "-o"); found && len(output) > 0 {
+		quitOnError(ioutil.WriteFile(output, result, 0644), "Writing [%s]", output)
+	} else {
+		_, _ = os.Stdout.Write(result)
+	}
+	exit(exitCodeOnSuccess)
+}
+
+func exportRequest(fullUrl, verb, data string, form *formBody, settings requestOptions) {
+	current := &exportedRequest{
+		name:   sampleLabel(settings, verb),
+		label:  settings.label,
+		method: strings.ToUpper(verb),
+		url:    escapedPlaceholderPattern.ReplaceAllString(fullUrl, "{{$1}}"),
+		form:   form,
+	}
+	if form == nil {
+		current.body = loadExternalFile(data)
+	}
+
+	extra := authHeaders(verb, fullUrl, data)
+	for key, value := range headers {
+		if _, overridden := extra[http.CanonicalHeaderKey(key)]; overridden || len(key) == 0 || len(value) == 0 {
+			continue
+		}
+		if form != nil && http.CanonicalHeaderKey(key) == headerContentType {
+			continue
+		}
+		current.headers = append(current.headers, [2]string{key, expand(value)})
+	}
+	for key := range extra {
+		current.headers = append(current.headers, [2]string{key, extra.Get(key)})
+	}
+	sort.Slice(current.headers, func(i, j int) bool { return current.headers[i][0] < current.headers[j][0] })
+
+	exported = append(exported, current)
+}
+
+// exportMap records the capture when the value comes from a response,
+// returns false when the MAP has nothing to do with the responses
+func exportMap(key, raw string) bool {
+	match := singleReference.FindStringSubmatch(strings.TrimSpace(raw))
+	if match == nil {
+		return false
+	}
+	label, namespace, param, found := exportReference(match[1])
+	if !found {
+		return false
+	}
+
+	source := exportSource(label)
+	if source == nil {
+		return false
+	}
+	source.captures = append(source.captures, exportCapture{variable: key, namespace: namespace, param: param})
+	setVariable(key, "{{"+key+"}}")
+	return true
+}
+
+func exportRequire(params string) {
+	if len(exported) == 0 {
+		return
+	}
+	last := exported[len(exported)-1]
+	last.checks = append(last.checks, params)
+}
+
+// exportReference breaks ${login.response:token} into its parts
+func exportReference(key string) (string, string, string, bool) {
+	name, param := splitBy(key, ":")
+	name = lower(name)
+	if _, found := typedNamespaces[name]; found {
+		return "", name, param, true
+	}
+	if dot := strings.Index(name, "."); dot > 0 {
+		if _, found := typedNamespaces[name[dot+1:]]; found {
+			return name[:dot], name[dot+1:], param, true
+		}
+	}
+	return "", "", "", false
+}
+
+// exportSource finds the request the value comes from: the labelled one or the last one
+func exportSource(label string) *exportedRequest {
+	for at := len(exported) - 1; at >= 0; at-- {
+		if len(label) == 0 || lower(exported[at].label) == label {
+			return exported[at]
+		}
+	}
+	return nil
+}
+
+// jsonPath splits the resolver's path (items/0/id, or the definite JSONPath $.items[0].id) into its steps
+func jsonPath(param string) []string {
+	steps := []string{}
+	if isJsonPath(param) {
+		parsed, _ := parseJsonPath(param)
+		for _, step := range parsed {
+			if len(step.indexes) == 1 {
+				steps = append(steps, strconv.Itoa(step.indexes[0]))
+			} else {
+				steps = append(steps, step.names...)
+			}
+		}
+		return steps
+	}
+	for _, step := range strings.Split(param, itemsSeparator) {
+		if len(step) > 0 && step != includeAllKey {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// exportable tells whether the value can be captured by the exported requests
+// (neither the JMESPath nor the JSONPath giving an array of the matches can)
+func (capture exportCapture) exportable() bool {
+	if capture.namespace != "response" || !isPathExpression(capture.param) {
+		return true
+	}
+	if strings.HasPrefix(lower(capture.param), jmesPrefix) {
+		return false
+	}
+	steps, err := parseJsonPath(capture.param)
+	if err != nil {
+		return false
+	}
+	for _, step := range steps {
+		if !step.definite() {
+			return false
+		}
+	}
+	return true
+}
+
+func isIndex(step string) bool {
+	_, err := strconv.Atoi(step)
+	return err == nil
+}
+
+// jqPath converts items/0/id into .items[0].id
+func jqPath(param string) string {
+	result := ""
+	for _, step := range jsonPath(param) {
+		switch {
+		case isIndex(step):
+			result += "[" + step + "]"
+		case identifierPattern.MatchString(step):
+			result += "." + step
+		default:
+			result += fmt.Sprintf(".[%q]", step)
+		}
+	}
+	if len(result) == 0 {
+		return "."
+	}
+	return result
+}
+
+// the shell formats: curl and httpie
+
+func shellQuote(src string) string {
+	quoted := "'" + strings.Replace(src, "'", `'\''`, -1) + "'"
+	// the captured values are kept in the shell variables
+	return placeholderPattern.ReplaceAllStringFunc(quoted, func(placeholder string) string {
+		return `'"$` + shellVariable(placeholder[2:len(placeholder)-2]) + `"'`
+	})
+}
+
+func shellVariable(name string) string {
+	return nonIdentifierPattern.ReplaceAllString(name, "_")
+}
+
+func exportShell(requests []*exportedRequest, command func(*exportedRequest, bool) []string) []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "#!/bin/sh\n# exported from %s\n", filepath.Base(filename()))
+	for _, current := range requests {
+		fmt.Fprintf(&out, "\n# %s\n", current.name)
+
+		capturing := len(current.captures) > 0
+		lines := command(current, capturing)
+		if capturing {
+			lines[0] = "response=$(" + lines[0]
+			lines[len(lines)-1] += ")"
+		}
+		fmt.Fprintln(&out, strings.Join(lines, " \\\n  "))
+
+		for _, capture := range current.captures {
+			switch {
+			case !capture.exportable():
+				fmt.Fprintf(&out, "# %s: cannot capture ${%s:%s} here\n", capture.variable, capture.namespace, capture.param)
+			case capture.namespace == "response":
+				fmt.Fprintf(&out, "%s=$(printf '%%s' \"$response\" | jq -r %s)\n", shellVariable(capture.variable), shellQuote(jqPath(capture.param)))
+			default:
+				fmt.Fprintf(&out, "# %s: cannot capture ${%s:%s} here\n", capture.variable, capture.namespace, capture.param)
+			}
+		}
+		for _, check := range current.checks {
+			fmt.Fprintf(&out, "# REQUIRE %s\n", check)
+		}
+	}
+	return out.Bytes()
+}
+
+func exportCurl(requests []*exportedRequest) []byte {
+	return exportShell(requests, func(current *exportedRequest, capturing bool) []string {
+		lines := []string{"curl --silent --show-error"}
+		if current.method == http.MethodHead {
+			lines = append(lines, "--head")
+		} else {
+			lines = append(lines, "--request "+current.method)
+		}
+		lines = append(lines, "--url "+shellQuote(current.url))
+		for _, header := range current.headers {
+			lines = append(lines, "--header "+shellQuote(header[0]+": "+header[1]))
+		}
+		switch {
+		case current.form != nil:
+			for _, argument := range current.form.curlArguments() {
+				lines = append(lines, argument[0]+" "+shellQuote(argument[1]))
+			}
+		case len(current.body) > 0:
+			lines = append(lines, "--data-raw "+shellQuote(current.body))
+		}
+		return lines
+	})
+}
+
+func exportHttpie(requests []*exportedRequest) []byte {
+	return exportShell(requests, func(current *exportedRequest, capturing bool) []string {
+		lines := []string{"http --ignore-stdin"}
+		if capturing {
+			lines[0] += " --print=b"
+		}
+		if current.form != nil {
+			if current.form.multipart {
+				lines = append(lines, "--multipart")
+			} else {
+				lines = append(lines, "--form")
+			}
+		}
+		if len(current.body) > 0 {
+			lines = append(lines, "--raw "+shellQuote(current.body))
+		}
+		lines = append(lines, current.method+" "+shellQuote(current.url))
+		for _, header := range current.headers {
+			lines = append(lines, shellQuote(header[0]+":"+header[1]))
+		}
+		if current.form != nil {
+			for _, field := range current.form.fields {
+				switch {
+				case field.file && current.form.multipart:
+					value := field.name + "@" + field.value
+					if len(field.contentType) > 0 {
+						value += ";type=" + field.contentType
+					}
+					lines = append(lines, shellQuote(value))
+				case field.file:
+					lines = append(lines, shellQuote(field.name+"=@"+field.value))
+				default:
+					lines = append(lines, shellQuote(field.name+"="+field.value))
+				}
+			}
+		}
+		return lines
+	})
+}
+
+// the .http files of the REST Client (VS Code) and the JetBrains' HTTP client
+
+func exportRestClient(requests []*exportedRequest) []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# exported from %s\n", filepath.Base(filename()))
+	for at, current := range requests {
+		name := current.label
+		if len(name) == 0 {
+			name = fmt.Sprintf("request%v", at+1)
+		}
+		fmt.Fprintf(&out, "\n### %s\n# @name %s\n", current.name, name)
+		fmt.Fprintf(&out, "%s %s\n", current.method, current.url)
+		for _, header := range current.headers {
+			fmt.Fprintf(&out, "%s: %s\n", header[0], header[1])
+		}
+
+		switch {
+		case current.form != nil && current.form.multipart:
+			const boundary = "gurl-boundary"
+			fmt.Fprintf(&out, "%s: multipart/form-data; boundary=%s\n\n", headerContentType, boundary)
+			for _, field := range current.form.fields {
+				fmt.Fprintf(&out, "--%s\n", boundary)
+				if field.file {
+					fmt.Fprintf(&out, "Content-Disposition: form-data; name=\"%s\"; filename=\"%s\"\n", escapeQuotes(field.name), escapeQuotes(field.attachmentName()))
+					fmt.Fprintf(&out, "%s: %s\n\n< %s\n", headerContentType, field.mimeType(), field.value)
+				} else {
+					fmt.Fprintf(&out, "Content-Disposition: form-data; name=\"%s\"\n\n%s\n", escapeQuotes(field.name), field.value)
+				}
+			}
+			fmt.Fprintf(&out, "--%s--\n", boundary)
+		case current.form != nil:
+			fmt.Fprintf(&out, "%s: %s\n\n", headerContentType, contentTypeForm)
+			_, encoded := current.form.encode()
+			fmt.Fprintln(&out, string(encoded))
+		case len(current.body) > 0:
+			fmt.Fprintf(&out, "\n%s\n", current.body)
+		}
+
+		for _, capture := range current.captures {
+			switch {
+			case !capture.exportable():
+				fmt.Fprintf(&out, "\n# %s: cannot capture ${%s:%s} here\n", capture.variable, capture.namespace, capture.param)
+			case capture.namespace == "response":
+				fmt.Fprintf(&out, "\n@%s = {{%s.response.body.$%s}}\n", capture.variable, name, strings.Replace(jqPath(capture.param), ".[", "[", -1))
+			case capture.namespace == "header":
+				fmt.Fprintf(&out, "\n@%s = {{%s.response.headers.%s}}\n", capture.variable, name, capture.param)
+			default:
+				fmt.Fprintf(&out, "\n# %s: cannot capture ${%s:%s} here\n", capture.variable, capture.namespace, capture.param)
+			}
+		}
+		for _, check := range current.checks {
+			fmt.Fprintf(&out, "# REQUIRE %s\n", check)
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// ${fake:name}, ${fake:email}, ${fake:phone}, ... - random (but plausible) data for the requests
+
+var (
+	fakeData = map[string]func() string{
+		"firstname": func() string { return pick(fakeFirstNames) },
+		"lastname":  func() string { return pick(fakeLastNames) },
+		"name":      func() string { return pick(fakeFirstNames) + " " + pick(fakeLastNames) },
+		"username":  fakeUsername,
+		"email":     func() string { return fakeUsername() + "@" + pick(fakeDomains) },
+		"password":  fakePassword,
+		"phone":     func() string { return fmt.Sprintf("+1-%03d-555-%04d", 200+rand.Intn(800), rand.Intn(10000)) },
+		"company":   func() string { return pick(fakeLastNames) + " " + pick(fakeCompanySuffixes) },
+		"street": func() string {
+			return fmt.Sprintf("%d %s %s", 1+rand.Intn(9999), pick(fakeLastNames), pick(fakeStreetSuffixes))
+		},
+		"city":     func() string { return pick(fakeCities) },
+		"country":  func() string { return pick(fakeCountries) },
+		"zip":      func() string { return fmt.Sprintf("%05d", rand.Intn(100000)) },
+		"domain":   func() string { return pick(fakeDomains) },
+		"url":      func() string { return "https://" + pick(fakeDomains) + "/" + pick(fakeWords) },
+		"ipv4":     func() string { return fmt.Sprintf("10.%d.%d.%d", rand.Intn(256), rand.Intn(256), 1+rand.Intn(254)) },
+		"word":     func() string { return pick(fakeWords) },
+		"sentence": fakeSentence,
+	}
+
+	fakeFirstNames      = []string{"James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda", "David", "Elizabeth", "William", "Barbara", "Richard", "Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Charles", "Karen", "Wei", "Aiko", "Olga", "Mateo", "Priya", "Kwame"}
+	fakeLastNames       = []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez", "Hernandez", "Lopez", "Wilson", "Anderson", "Taylor", "Moore", "Jackson", "Martin", "Lee", "Thompson", "Nakamura", "Ivanova", "Okafor", "Singh"}
+	fakeDomains         = []string{"example.com", "example.org", "example.net", "test.example.com", "mail.example.org"}
+	fakeCompanySuffixes = []string{"Inc", "LLC", "Group", "Partners", "Labs", "Systems", "Corporation"}
+	fakeStreetSuffixes  = []string{"Street", "Avenue", "Road", "Lane", "Boulevard", "Drive", "Court"}
+	fakeCities          = []string{"Springfield", "Riverside", "Fairview", "Franklin", "Greenville", "Bristol", "Clinton", "Madison", "Georgetown", "Salem"}
+	fakeCountries       = []string{"United States", "Canada", "United Kingdom", "Germany", "France", "Japan", "Australia", "Brazil", "India", "Spain"}
+	fakeWords           = []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india", "juliet", "kilo", "lima", "mike", "november", "oscar", "papa", "quebec", "romeo", "sierra", "tango", "uniform", "victor", "whiskey", "xray", "yankee", "zulu"}
+)
+
+const fakePasswordLetters = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789!#%+-_"
+
+func fakeValue(_, kind string) (bool, string) {
+	generator, found := fakeData[lower(strings.TrimSpace(kind))]
+	if !found {
+		reportError(fmt.Errorf("unknown kind [%s]", kind), "generating fake data, expected one of: %s", strings.Join(sortedFakeKinds(), ", "))
+		return false, ""
+	}
+	return true, generator()
+}
+
+func sortedFakeKinds() []string {
+	kinds := make(msi, len(fakeData))
+	for kind := range fakeData {
+		kinds[kind] = true
+	}
+	return sortedKeys(kinds)
+}
+
+func pick(from []string) string {
+	return from[rand.Intn(len(from))]
+}
+
+func fakeUsername() string {
+	return lower(pick(fakeFirstNames)) + "." + lower(pick(fakeLastNames)) + fmt.Sprintf("%d", rand.Intn(100))
+}
+
+func fakePassword() string {
+	password := make([]byte, 16)
+	for at := range password {
+		password[at] = fakePasswordLetters[rand.Intn(len(fakePasswordLetters))]
+	}
+	return string(password)
+}
+
+func fakeSentence() string {
+	words := make([]string, 4+rand.Intn(6))
+	for at := range words {
+		words[at] = pick(fakeWords)
+	}
+	words[0] = strings.ToUpper(words[0][:1]) + words[0][1:]
+	return strings.Join(words, " ") + "."
+}
@@ -6,75 +6,185 @@ package main
 
 import (
 	"encoding/json"
-	"strconv"
+	"regexp"
 	"strings"
-	"sync/atomic"
-
-	"github.com/rs/xid"
 )
 
+type namespaceHandler func(from *exchange, param string) (bool, interface{})
+
+// namespaces that give access to the last exchange, e.g. ${status}, ${header:Location},
+// or to a labelled one from the history, e.g. ${login.status}, ${login.response:token}
+var typedNamespaces = map[string]namespaceHandler{
+	"response":     responseAny,
+	"response.raw": rawResponseValue,
+	"status":       statusValue,
+	"status.text":  statusTextValue,
+	"header":       headerValue,
+	"timing":       timingValue,
+	"cookie":       cookieValue,
+}
+
 func setResolverFilters() {
 	resolver.SetFilter(preFilter, true)
 }
 
 func preFilter(key string) (bool, string) {
-	ley := lower(key)
-	switch ley {
-	case "random":
-		return true, xid.New().String()
-	case "increment":
-		return true, strconv.FormatInt(atomic.AddInt64(&incrementalCounter, 1), 10)
-	default:
-		if strings.HasPrefix(ley, mappingResponseValues) {
-			return responseValue(key[len(mappingResponseValues):])
+	if isBound(key) {
+		found, value := boundValue(key)
+		return found, valueToText(value)
+	}
+
+	if function, success, value := callFunction(key); function {
+		if !success {
+			return false, key
 		}
-		return false, key
+		return true, value
+	}
+
+	if strings.HasPrefix(lower(key), mappingResponseValues) {
+		return responseValue(key[len(mappingResponseValues):])
+	}
+	if found, value := typedValue(key); found {
+		return true, valueToText(value)
+	}
+	return false, key
+}
+
+// typedValue resolves the key to a value, keeping its (json) type intact
+func typedValue(key string) (bool, interface{}) {
+	if isBound(key) {
+		return boundValue(key)
 	}
+	if from, handler, param, found := findNamespace(key); found {
+		return handler(from, param)
+	}
+	return false, nil
+}
+
+// isTypedReference tells whether the key belongs to one of the typed namespaces,
+// so the failure to resolve it means the value is absent
+func isTypedReference(key string) bool {
+	if isBound(key) {
+		return true
+	}
+	_, _, _, found := findNamespace(key)
+	return found
+}
+
+func findNamespace(key string) (*exchange, namespaceHandler, string, bool) {
+	name, param := splitBy(key, ":")
+	name = lower(name)
+	if handler, found := typedNamespaces[name]; found {
+		return lastExchange, handler, param, true
+	}
+
+	if dot := strings.Index(name, "."); dot > 0 {
+		if handler, found := typedNamespaces[name[dot+1:]]; found {
+			label := name[:dot]
+			for known, from := range history {
+				if lower(known) == label {
+					return from, handler, param, true
+				}
+			}
+			// not a label (e.g. the variable order.status), left to the variables
+		}
+	}
+	return nil, nil, "", false
+}
+
+func responseAny(from *exchange, key string) (bool, interface{}) {
+	if from == nil || len(from.body) == 0 {
+		return false, nil
+	}
+
+	var holder interface{}
+	if err := json.Unmarshal(from.body, &holder); err != nil {
+		debug("response is not a json: %v", err)
+		return false, nil
+	}
+	if len(key) == 0 || key == includeAllKey {
+		return true, holder
+	}
+	return lookupAny(holder, key)
 }
 
 func responseValue(key string) (bool, string) {
-	// global savedResponse []byte
-	if len(savedResponse) == 0 {
+	if lastExchange == nil || len(lastExchange.body) == 0 {
 		return false, key
 	}
 
 	// handle special cases here:
 	if key == includeAllKey {
-		return true, string(savedResponse)
+		return true, string(lastExchange.body)
 	}
 
 	var holder interface{}
-	if err := json.Unmarshal(savedResponse, &holder); err != nil {
+	if err := json.Unmarshal(lastExchange.body, &holder); err != nil {
 		reportError(err, "failed to ingest json from response")
 		return false, key
 	}
 	return resolveAny(holder, key)
+}
 
-	/*
-		var holder msi
-		if err := json.Unmarshal(savedResponse, &holder); err != nil {
-			reportError(err, "failed to ingest json from response")
-			return false, key
-		}
+// ${response.raw} - the whole body, ${response.raw:id=(\d+)} - the first group matched by the expression
+func rawResponseValue(from *exchange, expression string) (bool, interface{}) {
+	if from == nil {
+		return false, nil
+	}
+	if len(expression) == 0 {
+		return true, string(from.body)
+	}
 
+	matcher, err := regexp.Compile(expression)
+	if err != nil {
+		reportError(err, "compiling regular expression [%s]", expression)
+		return false, nil
+	}
+	match := matcher.FindSubmatch(from.body)
+	switch len(match) {
+	case 0:
+		return false, nil
+	case 1:
+		return true, string(match[0])
+	default:
+		return true, string(match[1])
+	}
+}
 
+func statusValue(from *exchange, _ string) (bool, interface{}) {
+	if from == nil {
+		return false, nil
+	}
+	return true, from.status
+}
 
-		if data, found := holder[key]; found {
-			if txt, okay := data.(string); okay {
-				return true, txt
-			}
-		}
+func statusTextValue(from *exchange, _ string) (bool, interface{}) {
+	if from == nil {
+		return false, nil
+	}
+	return true, from.statusText
+}
 
-		//last resort ...
-		key = lower(key)
-		for name, settings := range holder {
-			if lower(name) == key {
-				if txt, okay := settings.(string); okay {
-					return true, txt
-				}
-			}
-		}
+func headerValue(from *exchange, name string) (bool, interface{}) {
+	if from == nil || len(name) == 0 {
+		return false, nil
+	}
+	if values := from.header.Values(name); len(values) > 0 {
+		return true, strings.Join(values, ", ")
+	}
+	return false, nil
+}
 
-		return false, key
-	*/
+// ${timing:total} - duration (in milliseconds) of the given phase of the last exchange
+func timingValue(from *exchange, phase string) (bool, interface{}) {
+	if from == nil {
+		return false, nil
+	}
+	if len(phase) == 0 {
+		phase = timingTotal
+	}
+	if duration, found := from.timing[lower(phase)]; found {
+		return true, float64(duration.Microseconds()) / 1000
+	}
+	return false, nil
 }
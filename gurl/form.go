@@ -0,0 +1,193 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// POST /login FORM user=${user} password=${password}
+//
+// POST /upload
+// MULTIPART
+// title=My holiday
+// photo=@pictures/beach.png;type=image/png;filename=beach.png
+//
+// the fields may follow the keyword on the same line and/or be listed one per line. the words
+// without = continue the value of the preceding field, the values with = after a space go in double quotes
+
+type (
+	formBody struct {
+		multipart bool
+		fields    []formField
+	}
+
+	formField struct {
+		name        string
+		value       string
+		file        bool
+		contentType string
+		filename    string
+	}
+)
+
+const (
+	formKeyword      = "form"
+	multipartKeyword = "multipart"
+)
+
+// parseFormBody returns nil when the (unexpanded) payload is not built with FORM/MULTIPART
+func parseFormBody(payload string) *formBody {
+	keyword, fields := split(payload)
+	keyword = lower(keyword)
+	if keyword != formKeyword && keyword != multipartKeyword {
+		return nil
+	}
+
+	body := &formBody{multipart: keyword == multipartKeyword}
+	for _, raw := range formFields(fields) {
+		body.fields = append(body.fields, parseFormField(expand(raw), body.multipart))
+	}
+	return body
+}
+
+// formFields splits the fields (the lines of a multi-line request come joined with spaces):
+// a word without = belongs to the value of the preceding field, so title=My holiday stays one field
+func formFields(src string) []string {
+	fields := []string{}
+	for _, word := range splitArguments(src) {
+		if len(fields) > 0 && !strings.Contains(word, "=") {
+			fields[len(fields)-1] += " " + word
+			continue
+		}
+		fields = append(fields, word)
+	}
+	return fields
+}
+
+// name=value, name=@file or (multipart only) name=@file;type=image/png;filename=other.png
+func parseFormField(raw string, multipart bool) formField {
+	parts := strings.SplitN(raw, "=", 2)
+	if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+		quit("form field is expected to be name=value, got [%s]", raw)
+	}
+
+	field := formField{name: strings.TrimSpace(parts[0]), value: parts[1]}
+	if !strings.HasPrefix(field.value, externalFilePrefix) {
+		return field
+	}
+
+	field.file = true
+	attributes := strings.Split(field.value[len(externalFilePrefix):], ";")
+	field.value = attributes[0]
+	for _, attribute := range attributes[1:] {
+		key, value := splitBy(attribute, "=")
+		switch lower(key) {
+		case "type":
+			field.contentType = value
+		case "filename":
+			field.filename = value
+		default:
+			quit("unknown attribute [%s] of form field [%s]", attribute, field.name)
+		}
+	}
+	if !multipart && (len(field.contentType) > 0 || len(field.filename) > 0) {
+		quit("FORM field [%s] cannot have type/filename, use MULTIPART", field.name)
+	}
+	return field
+}
+
+// encode returns the content type and the body
+func (body *formBody) encode() (string, []byte) {
+	if !body.multipart {
+		values := url.Values{}
+		for _, field := range body.fields {
+			value := field.value
+			if field.file {
+				data, err := ioutil.ReadFile(field.value)
+				quitOnError(err, "Opening file [%s]", field.value)
+				value = string(data)
+			}
+			values.Add(field.name, value)
+		}
+		return contentTypeForm, []byte(values.Encode())
+	}
+
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+	for _, field := range body.fields {
+		if !field.file {
+			quitOnError(writer.WriteField(field.name, field.value), "Writing form field [%s]", field.name)
+			continue
+		}
+
+		data, err := ioutil.ReadFile(field.value)
+		quitOnError(err, "Opening file [%s]", field.value)
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(field.name), escapeQuotes(field.attachmentName())))
+		header.Set(headerContentType, field.mimeType())
+		part, err := writer.CreatePart(header)
+		quitOnError(err, "Creating form part [%s]", field.name)
+		_, err = part.Write(data)
+		quitOnError(err, "Writing form part [%s]", field.name)
+	}
+	quitOnError(writer.Close(), "Finishing multipart body")
+	return writer.FormDataContentType(), buffer.Bytes()
+}
+
+func (field formField) attachmentName() string {
+	if len(field.filename) > 0 {
+		return field.filename
+	}
+	return filepath.Base(field.value)
+}
+
+func (field formField) mimeType() string {
+	if len(field.contentType) > 0 {
+		return field.contentType
+	}
+	if guess := mime.TypeByExtension(filepath.Ext(field.value)); len(guess) > 0 {
+		return guess
+	}
+	return "application/octet-stream"
+}
+
+// curlArguments returns the matching --form / --data-urlencode options (and their values)
+func (body *formBody) curlArguments() [][2]string {
+	arguments := make([][2]string, 0, len(body.fields))
+	for _, field := range body.fields {
+		switch {
+		case body.multipart && field.file:
+			value := "@" + field.value
+			if len(field.contentType) > 0 {
+				value += ";type=" + field.contentType
+			}
+			if len(field.filename) > 0 {
+				value += ";filename=" + field.filename
+			}
+			arguments = append(arguments, [2]string{"--form", field.name + "=" + value})
+		case body.multipart:
+			arguments = append(arguments, [2]string{"--form-string", field.name + "=" + field.value})
+		case field.file:
+			arguments = append(arguments, [2]string{"--data-urlencode", field.name + "@" + field.value})
+		default:
+			arguments = append(arguments, [2]string{"--data-urlencode", field.name + "=" + field.value})
+		}
+	}
+	return arguments
+}
+
+func escapeQuotes(src string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(src)
+}
@@ -0,0 +1,255 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	mathrand "math/rand"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// ${base64:text}, ${base64url:text}, ${unbase64:text}
+// ${urlencode:text}
+// ${md5:text}, ${sha1:text}, ${sha256:text}, ${sha512:text}	(hex)
+// ${hmac:key:text}												(hex of HMAC-SHA256)
+// ${now}, ${now:unix}, ${now+1h:RFC3339}, ${now-7d:2006-01-02}	(UTC; the format is a name or a go layout)
+// ${uuid}, ${random}, ${increment}
+// ${env:HOME}
+// ${file:./token.txt}											(without the trailing new line)
+// ${fake:email}, ${fake:name}, ...								(see fakeData)
+// ${int:1:100}, ${int:10}										(inclusive)
+//
+// the functions nest: ${base64:${env:USER}:${file:~/.password}}
+
+type (
+	resolverFunction struct {
+		call func(name, param string) (bool, string)
+		// can be used without the parameters, e.g. ${uuid}
+		bare bool
+	}
+)
+
+var (
+	resolverFunctions = map[string]resolverFunction{
+		"random":    {call: randomValue, bare: true},
+		"increment": {call: incrementValue, bare: true},
+		"uuid":      {call: uuidValue, bare: true},
+		"now":       {call: nowValue, bare: true},
+		"base64":    {call: base64Value},
+		"base64url": {call: base64Value},
+		"unbase64":  {call: unbase64Value},
+		"urlencode": {call: urlencodeValue},
+		"md5":       {call: hashValue},
+		"sha1":      {call: hashValue},
+		"sha256":    {call: hashValue},
+		"sha512":    {call: hashValue},
+		"hmac":      {call: hmacValue},
+		"env":       {call: envValue},
+		"file":      {call: fileValue},
+		"fake":      {call: fakeValue},
+		"int":       {call: intValue},
+	}
+
+	hashes = map[string]func() hash.Hash{
+		"md5":    md5.New,
+		"sha1":   sha1.New,
+		"sha256": sha256.New,
+		"sha512": sha512.New,
+	}
+
+	timeFormats = map[string]string{
+		"ansic":       time.ANSIC,
+		"rfc822":      time.RFC822,
+		"rfc822z":     time.RFC822Z,
+		"rfc850":      time.RFC850,
+		"rfc1123":     time.RFC1123,
+		"rfc1123z":    time.RFC1123Z,
+		"rfc3339":     time.RFC3339,
+		"rfc3339nano": time.RFC3339Nano,
+		"kitchen":     time.Kitchen,
+		"date":        "2006-01-02",
+		"time":        "15:04:05",
+		"http":        http1123,
+	}
+)
+
+// http1123 is the format of the Date (and the likes) header
+const http1123 = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// callFunction evaluates ${name:param} when the name is one of the functions
+func callFunction(key string) (bool, bool, string) {
+	name, param, hasParam := key, "", false
+	if colon := strings.Index(key, ":"); colon >= 0 {
+		name, param, hasParam = key[:colon], key[colon+1:], true
+	}
+	name = lower(strings.TrimSpace(name))
+
+	function, found := resolverFunctions[functionName(name)]
+	if !found || (!hasParam && !function.bare) {
+		return false, false, ""
+	}
+	success, value := function.call(name, param)
+	return true, success, value
+}
+
+// functionName drops the offset of now+1h (and the likes)
+func functionName(name string) string {
+	if strings.HasPrefix(name, "now+") || strings.HasPrefix(name, "now-") {
+		return "now"
+	}
+	return name
+}
+
+func randomValue(_, _ string) (bool, string) {
+	return true, xid.New().String()
+}
+
+func incrementValue(_, _ string) (bool, string) {
+	return true, strconv.FormatInt(atomic.AddInt64(&incrementalCounter, 1), 10)
+}
+
+// uuidValue gives a random (version 4) uuid
+func uuidValue(_, _ string) (bool, string) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		reportError(err, "generating uuid")
+		return false, ""
+	}
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+	return true, fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
+
+func nowValue(name, format string) (bool, string) {
+	moment := time.Now().UTC()
+	if offset := name[len("now"):]; len(offset) > 0 {
+		duration, err := parseOffset(offset)
+		if err != nil {
+			reportError(err, "parsing time offset [%s]", offset)
+			return false, ""
+		}
+		moment = moment.Add(duration)
+	}
+
+	switch lower(format) {
+	case "unix":
+		return true, strconv.FormatInt(moment.Unix(), 10)
+	case "unixms", "millis":
+		return true, strconv.FormatInt(moment.UnixNano()/int64(time.Millisecond), 10)
+	case "unixnano":
+		return true, strconv.FormatInt(moment.UnixNano(), 10)
+	case "":
+		format = time.RFC3339
+	}
+	if layout, found := timeFormats[lower(format)]; found {
+		format = layout
+	}
+	return true, moment.Format(format)
+}
+
+// parseOffset is time.ParseDuration that knows the days too (+7d)
+func parseOffset(offset string) (time.Duration, error) {
+	if strings.HasSuffix(offset, "d") {
+		days, err := strconv.Atoi(strings.TrimPrefix(offset[:len(offset)-1], "+"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(offset)
+}
+
+func base64Value(name, text string) (bool, string) {
+	if name == "base64url" {
+		return true, base64.RawURLEncoding.EncodeToString([]byte(text))
+	}
+	return true, base64.StdEncoding.EncodeToString([]byte(text))
+}
+
+func unbase64Value(_, text string) (bool, string) {
+	text = strings.TrimSpace(text)
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err := encoding.DecodeString(text); err == nil {
+			return true, string(data)
+		}
+	}
+	reportError(fmt.Errorf("not a base64 text"), "decoding [%s]", text)
+	return false, ""
+}
+
+func urlencodeValue(_, text string) (bool, string) {
+	return true, url.QueryEscape(text)
+}
+
+func hashValue(name, text string) (bool, string) {
+	digest := hashes[name]()
+	digest.Write([]byte(text))
+	return true, hex.EncodeToString(digest.Sum(nil))
+}
+
+func hmacValue(_, param string) (bool, string) {
+	colon := strings.Index(param, ":")
+	if colon < 0 {
+		reportError(fmt.Errorf("expected ${hmac:key:text}"), "computing hmac of [%s]", param)
+		return false, ""
+	}
+	mac := hmac.New(sha256.New, []byte(param[:colon]))
+	mac.Write([]byte(param[colon+1:]))
+	return true, hex.EncodeToString(mac.Sum(nil))
+}
+
+func envValue(_, name string) (bool, string) {
+	value, found := os.LookupEnv(strings.TrimSpace(name))
+	if !found {
+		debug("there is no environment variable [%s]", name)
+	}
+	return found, value
+}
+
+func fileValue(_, name string) (bool, string) {
+	path, err := expandPath(strings.TrimSpace(name))
+	if err == nil {
+		var data []byte
+		if data, err = ioutil.ReadFile(path); err == nil {
+			return true, strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+		}
+	}
+	reportError(err, "reading file [%s]", name)
+	return false, ""
+}
+
+// ${int:1:100} - from 1 to 100, ${int:10} - from 0 to 10
+func intValue(_, param string) (bool, string) {
+	low, high := "0", param
+	if colon := strings.Index(param, ":"); colon >= 0 {
+		low, high = param[:colon], param[colon+1:]
+	}
+	from, err := strconv.ParseInt(strings.TrimSpace(low), 10, 64)
+	if err == nil {
+		var to int64
+		if to, err = strconv.ParseInt(strings.TrimSpace(high), 10, 64); err == nil && to >= from {
+			return true, strconv.FormatInt(from+mathrand.Int63n(to-from+1), 10)
+		}
+	}
+	reportError(fmt.Errorf("expected ${int:min:max}"), "generating number [%s]", param)
+	return false, ""
+}
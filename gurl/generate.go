@@ -0,0 +1,479 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// gurl generate --openapi api.yaml [--by tag|operation] [--output dir]
+//
+// produces a smoke-test script per tag (or per operation): a SECTION per operation with
+// the example body built from the schema, the path (and required query/header) parameters
+// as MAP placeholders at the top and a REQUIRE of the documented success status.
+// without --output all the scripts are printed (as one) to stdout.
+
+type generatedScript struct {
+	name       string
+	operations []apiOperation
+}
+
+var (
+	generateGroupings = map[string]bool{"tag": true, "operation": true}
+
+	pathParameterPattern = regexp.MustCompile(`\{([^{}]+)\}`)
+	fileNamePattern      = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+	// the headers the script takes care of itself
+	generateSkippedHeaders = map[string]bool{
+		lower(headerAuthorization): true,
+		lower(headerContentType):   true,
+		"accept":                   true,
+	}
+)
+
+func runGenerate() {
+	source, _ := optionValue("--openapi", "-openapi")
+	if len(source) == 0 {
+		source = filename()
+	}
+	by, _ := optionValue("--by", "-by")
+	if len(by) == 0 {
+		by = "tag"
+	}
+	if !generateGroupings[lower(by)] {
+		quit("generate expects --by tag or operation, got [%s]", by)
+	}
+
+	swagger := loadApiSpec(source)
+	scripts := groupOperations(swagger, lower(by))
+	if len(scripts) == 0 {
+		quit("there are no operations in [%s]", source)
+	}
+
+	output, _ := optionValue("--output", "-output", "-o")
+	if len(output) == 0 {
+		all := []apiOperation{}
+		for _, script := range scripts {
+			all = append(all, script.operations...)
+		}
+		_, _ = os.Stdout.Write(writeGenerated(swagger, source, "", all))
+		exit(exitCodeOnSuccess)
+	}
+
+	quitOnError(os.MkdirAll(output, 0755), "Creating directory [%s]", output)
+	for _, script := range scripts {
+		name := filepath.Join(output, strings.Trim(fileNamePattern.ReplaceAllString(script.name, "-"), "-")+".gurl")
+		quitOnError(ioutil.WriteFile(name, writeGenerated(swagger, source, script.name, script.operations), 0644), "Writing [%s]", name)
+		comment(echoProgress, "generated %s: %v operation(s)", name, len(script.operations))
+	}
+	exit(exitCodeOnSuccess)
+}
+
+// groupOperations returns the scripts in the order of the tags in the spec (then by name)
+func groupOperations(swagger *spec.Swagger, by string) []*generatedScript {
+	scripts := []*generatedScript{}
+	byName := map[string]*generatedScript{}
+	add := func(name string, operation apiOperation) {
+		script, found := byName[name]
+		if !found {
+			script = &generatedScript{name: name}
+			byName[name] = script
+			scripts = append(scripts, script)
+		}
+		script.operations = append(script.operations, operation)
+	}
+
+	for _, operation := range apiOperations(swagger) {
+		switch {
+		case by == "operation":
+			add(operationName(operation), operation)
+		case len(operation.operation.Tags) > 0:
+			add(operation.operation.Tags[0], operation)
+		default:
+			add(importOtherSection, operation)
+		}
+	}
+
+	if by == "tag" {
+		order := map[string]int{}
+		for at, tag := range swagger.Tags {
+			order[tag.Name] = at + 1
+		}
+		sort.SliceStable(scripts, func(i, j int) bool {
+			left, right := order[scripts[i].name], order[scripts[j].name]
+			if left == 0 || right == 0 {
+				return left > right || (left == right && scripts[i].name < scripts[j].name)
+			}
+			return left < right
+		})
+	}
+	return scripts
+}
+
+func operationName(operation apiOperation) string {
+	if len(operation.operation.ID) > 0 {
+		return operation.operation.ID
+	}
+	return operation.method + " " + operation.path
+}
+
+func writeGenerated(swagger *spec.Swagger, source, group string, operations []apiOperation) []byte {
+	var out bytes.Buffer
+	title := filepath.Base(source)
+	if swagger.Info != nil && len(swagger.Info.Title) > 0 {
+		title = strings.TrimSpace(swagger.Info.Title + " " + swagger.Info.Version)
+	}
+	fmt.Fprintf(&out, "# smoke test of %s, generated from %s\n", oneLine(title), filepath.Base(source))
+	if len(group) > 0 {
+		fmt.Fprintf(&out, "# %s\n", oneLine(group))
+	}
+	fmt.Fprintf(&out, "SET BaseUrl %s\n", apiBaseUrl(swagger))
+	fmt.Fprintf(&out, "HEADER Accept %s\n", contentTypeJson)
+	fmt.Fprintf(&out, "HEADER %s %s\n", headerContentType, contentTypeJson)
+	writeSecurity(&out, swagger)
+
+	// the placeholders (the first example wins when the name is used more than once)
+	placeholders := [][2]string{}
+	seen := map[string]bool{}
+	for _, operation := range operations {
+		for _, parameter := range operation.params {
+			if !placeholderParameter(parameter) || seen[shellVariable(parameter.Name)] {
+				continue
+			}
+			seen[shellVariable(parameter.Name)] = true
+			placeholders = append(placeholders, [2]string{shellVariable(parameter.Name), parameterExample(parameter)})
+		}
+	}
+	if len(placeholders) > 0 {
+		fmt.Fprintf(&out, "\n# the values to replace with the real ones\n")
+	}
+	for _, placeholder := range placeholders {
+		fmt.Fprintf(&out, "MAP %s %s\n", placeholder[0], placeholder[1])
+	}
+
+	for _, operation := range operations {
+		fmt.Fprintf(&out, "\nSECTION %s\n\n", oneLine(operationName(operation)))
+		writeOperation(&out, swagger, operation)
+	}
+	return out.Bytes()
+}
+
+func apiBaseUrl(swagger *spec.Swagger) string {
+	scheme := "https"
+	if len(swagger.Schemes) > 0 {
+		scheme = swagger.Schemes[0]
+	}
+	host := swagger.Host
+	if len(host) == 0 {
+		host = "localhost"
+	}
+	return scheme + "://" + host + strings.TrimSuffix(swagger.BasePath, "/")
+}
+
+// writeSecurity suggests the AUTH (or HEADER) matching the security definitions
+func writeSecurity(out *bytes.Buffer, swagger *spec.Swagger) {
+	if len(swagger.SecurityDefinitions) == 0 {
+		return
+	}
+	names := make([]string, 0, len(swagger.SecurityDefinitions))
+	for name := range swagger.SecurityDefinitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(out, "\n# the api is secured, uncomment the matching line:\n")
+	for _, name := range names {
+		scheme := swagger.SecurityDefinitions[name]
+		switch lower(scheme.Type) {
+		case "basic":
+			fmt.Fprintf(out, "# %s: AUTH basic ${user} ${password}\n", name)
+		case "apikey":
+			if bearer, _ := scheme.Extensions.GetString("x-scheme"); len(bearer) > 0 {
+				fmt.Fprintf(out, "# %s: AUTH bearer ${token}\n", name)
+			} else if lower(scheme.In) == "header" {
+				fmt.Fprintf(out, "# %s: HEADER %s ${apiKey}\n", name, scheme.Name)
+			} else {
+				fmt.Fprintf(out, "# %s: add %s=${apiKey} to the %s of every request\n", name, scheme.Name, scheme.In)
+			}
+		case "oauth2":
+			tokenUrl := scheme.TokenURL
+			if len(tokenUrl) == 0 {
+				tokenUrl = generatedPlaceholder
+			}
+			fmt.Fprintf(out, "# %s: AUTH oauth2 grant=client_credentials token.url=%s client.id=${clientId} client.secret=${clientSecret}\n", name, tokenUrl)
+		default:
+			fmt.Fprintf(out, "# %s: %s\n", name, scheme.Type)
+		}
+	}
+}
+
+func writeOperation(out *bytes.Buffer, swagger *spec.Swagger, operation apiOperation) {
+	details := operation.operation
+	if len(details.Summary) > 0 {
+		fmt.Fprintf(out, "# %s\n", oneLine(details.Summary))
+	}
+	if details.Deprecated {
+		fmt.Fprintf(out, "# deprecated\n")
+	}
+
+	target := pathParameterPattern.ReplaceAllStringFunc(operation.path, func(name string) string {
+		return "${" + shellVariable(name[1:len(name)-1]) + "}"
+	})
+	query := []string{}
+	headerNames := []string{}
+	var body *spec.Parameter
+	fields := []spec.Parameter{}
+	for at, parameter := range operation.params {
+		switch lower(parameter.In) {
+		case "query":
+			if parameter.Required {
+				query = append(query, parameter.Name+"=${"+shellVariable(parameter.Name)+"}")
+			}
+		case "header":
+			if placeholderParameter(parameter) {
+				fmt.Fprintf(out, "HEADER %s ${%s}\n", parameter.Name, shellVariable(parameter.Name))
+				headerNames = append(headerNames, parameter.Name)
+			}
+		case "body":
+			body = &operation.params[at]
+		case "formdata":
+			fields = append(fields, parameter)
+		case "cookie":
+			if parameter.Required {
+				fmt.Fprintf(out, "# note: the cookie [%s] is expected\n", parameter.Name)
+			}
+		}
+	}
+	if len(query) > 0 {
+		target += "?" + strings.Join(query, "&")
+	}
+
+	command := operation.method
+	if !importVerbs[command] {
+		command = "REQUEST " + command
+	}
+	fmt.Fprintf(out, "%s %s\n", command, target)
+	switch {
+	case len(fields) > 0:
+		writeFormFields(out, details, fields)
+	case body != nil && body.Schema != nil:
+		example, err := json.Marshal(exampleValue(swagger, body.Schema))
+		quitOnError(err, "Building the example body of [%s]", operationName(operation))
+		if bytes.Contains(example, []byte(commentPrefix)) {
+			fmt.Fprintf(out, "# note: the example body contains '#', which starts a comment in a script: save it into a file and use @file\n")
+		} else {
+			fmt.Fprintln(out, string(example))
+		}
+	}
+	fmt.Fprintln(out)
+
+	if status := successStatus(details); status > 0 {
+		fmt.Fprintf(out, "REQUIRE ${status} == %v\n", status)
+	} else {
+		fmt.Fprintf(out, "REQUIRE ${status} < 400\n")
+	}
+	for _, name := range headerNames {
+		fmt.Fprintf(out, "HEADER %s\n", name)
+	}
+}
+
+func writeFormFields(out *bytes.Buffer, operation *spec.Operation, fields []spec.Parameter) {
+	multipart := false
+	for _, consumes := range operation.Consumes {
+		multipart = multipart || strings.HasPrefix(lower(consumes), "multipart/")
+	}
+	for _, field := range fields {
+		multipart = multipart || field.Type == "file"
+	}
+	if multipart {
+		fmt.Fprintln(out, "MULTIPART")
+	} else {
+		fmt.Fprintln(out, "FORM")
+	}
+	for _, field := range fields {
+		if field.Type == "file" {
+			fmt.Fprintf(out, "%s=%s%s\n", field.Name, externalFilePrefix, generatedPlaceholder)
+		} else {
+			fmt.Fprintf(out, "%s=%s\n", field.Name, parameterExample(field))
+		}
+	}
+}
+
+// placeholderParameter tells whether the parameter gets a MAP placeholder
+func placeholderParameter(parameter spec.Parameter) bool {
+	switch lower(parameter.In) {
+	case "path":
+		return true
+	case "query":
+		return parameter.Required
+	case "header":
+		return parameter.Required && !generateSkippedHeaders[lower(parameter.Name)]
+	}
+	return false
+}
+
+func parameterExample(parameter spec.Parameter) string {
+	if example, found := parameter.Extensions["x-example"]; found {
+		return fmt.Sprint(example)
+	}
+	switch {
+	case parameter.Example != nil:
+		return fmt.Sprint(parameter.Example)
+	case parameter.Default != nil:
+		return fmt.Sprint(parameter.Default)
+	case len(parameter.Enum) > 0:
+		return fmt.Sprint(parameter.Enum[0])
+	}
+	switch parameter.Type {
+	case "integer", "number":
+		if parameter.Minimum != nil {
+			return fmt.Sprint(*parameter.Minimum)
+		}
+		return "1"
+	case "boolean":
+		return "true"
+	case "string":
+		if example, known := formatExample(parameter.Format); known {
+			return fmt.Sprint(example)
+		}
+	}
+	return generatedPlaceholder
+}
+
+type exampleBuilder struct {
+	swagger *spec.Swagger
+	// the definitions being built (to break the cycles)
+	building map[string]bool
+}
+
+// exampleValue builds a value matching the schema (the examples, defaults and enums first)
+func exampleValue(swagger *spec.Swagger, schema *spec.Schema) interface{} {
+	builder := &exampleBuilder{swagger: swagger, building: map[string]bool{}}
+	return builder.value(schema, 0)
+}
+
+func (builder *exampleBuilder) value(schema *spec.Schema, depth int) interface{} {
+	if schema != nil && len(schema.Ref.String()) > 0 {
+		ref := schema.Ref.String()
+		if builder.building[ref] {
+			return nil
+		}
+		builder.building[ref] = true
+		defer delete(builder.building, ref)
+	}
+
+	schema = resolveSchema(builder.swagger, schema)
+	if schema == nil || depth > exampleDepthLimit {
+		return nil
+	}
+	switch {
+	case schema.Example != nil:
+		return schema.Example
+	case schema.Default != nil:
+		return schema.Default
+	case len(schema.Enum) > 0:
+		return schema.Enum[0]
+	case len(schema.AllOf) > 0:
+		merged := builder.object(schema, depth)
+		for at := range schema.AllOf {
+			if part, ok := builder.value(&schema.AllOf[at], depth+1).(map[string]interface{}); ok {
+				for key, value := range part {
+					merged[key] = value
+				}
+			}
+		}
+		return merged
+	case len(schema.OneOf) > 0:
+		return builder.value(&schema.OneOf[0], depth+1)
+	case len(schema.AnyOf) > 0:
+		return builder.value(&schema.AnyOf[0], depth+1)
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		return builder.object(schema, depth)
+	case "array":
+		items := []interface{}{}
+		if schema.Items == nil {
+			return items
+		}
+		item := schema.Items.Schema
+		if item == nil && len(schema.Items.Schemas) > 0 {
+			item = &schema.Items.Schemas[0]
+		}
+		if value := builder.value(item, depth+1); value != nil {
+			items = append(items, value)
+		}
+		return items
+	case "integer", "number":
+		if schema.Minimum != nil {
+			return *schema.Minimum
+		}
+		return 0
+	case "boolean":
+		return true
+	case "string":
+		example, _ := formatExample(schema.Format)
+		return example
+	}
+	return nil
+}
+
+func (builder *exampleBuilder) object(schema *spec.Schema, depth int) map[string]interface{} {
+	result := map[string]interface{}{}
+	for name, property := range schema.Properties {
+		if resolved := resolveSchema(builder.swagger, &property); resolved != nil && resolved.ReadOnly {
+			// set by the server
+			continue
+		}
+		if value := builder.value(&property, depth+1); value != nil {
+			result[name] = value
+		}
+	}
+	if len(schema.Properties) == 0 && schema.AdditionalProperties != nil && schema.AdditionalProperties.Schema != nil {
+		if value := builder.value(schema.AdditionalProperties.Schema, depth+1); value != nil {
+			result["key"] = value
+		}
+	}
+	return result
+}
+
+// formatExample returns the example string of the format, false when the format is not a known one
+func formatExample(format string) (interface{}, bool) {
+	switch lower(format) {
+	case "date-time":
+		return "2019-01-01T00:00:00Z", true
+	case "date":
+		return "2019-01-01", true
+	case "time":
+		return "00:00:00", true
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000", true
+	case "email":
+		return "user@example.com", true
+	case "uri", "url":
+		return "https://example.com", true
+	case "hostname":
+		return "example.com", true
+	case "ipv4":
+		return "127.0.0.1", true
+	case "ipv6":
+		return "::1", true
+	case "byte":
+		return "Z3VybA==", true
+	}
+	return "string", false
+}
@@ -13,7 +13,8 @@ import (
 )
 
 func main() {
-	if len(os.Args) < 2 || help(filename()) {
+	parseArguments()
+	if helpRequested() {
 		usage()
 	}
 	debug("args: %v, %v", len(os.Args), os.Args)
@@ -22,79 +23,41 @@ func main() {
 
 	printer.Set(debug) // todo: revisit this
 
+	loadSession()
+
+	if handler, found := subcommands[subcommand]; found {
+		handler()
+	}
+
+	if loadTesting() {
+		runLoadTest()
+	}
+
 	data, err := ioutil.ReadFile(filename())
 	quitOnError(err, "Opening file %s", filename())
 
 	comment(echoProgress, "Processing file %s", filename())
 	generate("# generating curls commands from %s", filename())
 	currentFile = filename()
+	if loadWorker {
+		runLoadWorker(string(data))
+	}
 	processScript(string(data))
 
-	os.Exit(exitCodeOnSuccess)
+	exit(finalExitCode())
 }
 
 func processScript(script string) {
-	lines := strings.Split(script, lineSeparator)
-
-	// skip shebang
-	offset := 1
-	if strings.HasPrefix(lines[0], shebang) {
-		lines = lines[1:]
-		offset++
-	}
-
-	command := []string{}
-	insideCommentBlock := false
-
-	for lineNumber, line := range lines {
-		currentLineNumber = lineNumber + offset
-
-		// ignore whitespace
-		line = strings.TrimLeft(line, leadingWhiteSpace)
-		line = strings.TrimRight(line, trainingWhiteSpace)
-
-		if insideCommentBlock {
-			if strings.HasSuffix(line, "*/") {
-				insideCommentBlock = false
-			}
-			continue
+	statements := parseScript(script, currentFile)
+	if len(runSection) > 0 {
+		// -section: the preamble and the matching sections only
+		preamble, selected := selectSection(statements, runSection)
+		if len(selected) == 0 {
+			quit("cannot find SECTION [%s]", runSection)
 		}
-
-		if strings.HasPrefix(line, "/*") {
-			if !strings.HasSuffix(line, "*/") {
-				insideCommentBlock = true
-			}
-			continue
-		}
-
-		// ignore comments
-		if strings.HasPrefix(line, commentPrefix) {
-			continue
-		}
-		if pound := strings.Index(line, commentPrefix); pound > 0 {
-			line = strings.TrimSpace(line[:pound])
-		}
-
-		if len(line) == 0 {
-			processCommand(strings.Join(command, " "))
-			command = []string{}
-		} else {
-			if len(command) == 0 {
-				cmd, _ := split(line)
-				if !multiLineCommand(cmd) {
-					processCommand(line)
-					continue
-				}
-			}
-			command = append(command, line)
-		}
-	}
-
-	// deal with the remains ...
-	if len(command) > 0 {
-		processCommand(strings.Join(command, " "))
-		command = []string{}
+		statements = append(preamble, selected...)
 	}
+	runStatements(statements)
 }
 
 func processCommand(command string) {
@@ -118,18 +81,45 @@ func processCommand(command string) {
 
 type cmdHandler func(params, options string)
 
-var handlers = map[string]cmdHandler{
-	"set":    processSet,
-	"map":    processMap,
-	"header": processHeader,
-
-	"get":    processGet,
-	"patch":  processPatch,
-	"post":   processPost,
-	"delete": processDelete,
+var handlers map[string]cmdHandler
+
+// the subcommands (e.g. gurl export --format=postman script.gurl)
+// take over the whole run and exit when done
+var subcommands map[string]func()
+
+func init() {
+	// some of the handlers run the statements themselves, hence the late initialization
+	handlers = map[string]cmdHandler{
+		"set":    processSet,
+		"map":    processMap,
+		"header": processHeader,
+
+		"get":    processGet,
+		"patch":  processPatch,
+		"post":   processPost,
+		"put":    processPut,
+		"delete": processDelete,
+
+		"head":    processHead,
+		"options": processOptions,
+		"request": processRequest,
+
+		"echo":    processEcho,
+		"require": processRequire,
+		"load":    processLoad,
+		"section": processSection,
+		"include": processInclude,
+		"call":    processCall,
+		"cookie":  processCookie,
+		"auth":    processAuth,
+	}
 
-	"echo":    processEcho,
-	"require": processRequire,
-	"load":    processLoad,
-	"section": processSection,
+	subcommands = map[string]func(){
+		"export":   runExport,
+		"import":   runImport,
+		"generate": runGenerate,
+		"serve":    runServe,
+		"settings": runSettings,
+		"test":     runTests,
+	}
 }
@@ -0,0 +1,134 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// HAR (HTTP Archive, format 1.2) as saved by the browsers
+
+type (
+	harArchive struct {
+		Log struct {
+			Creator struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"creator"`
+			Pages []struct {
+				Id    string `json:"id"`
+				Title string `json:"title"`
+			} `json:"pages"`
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+
+	harEntry struct {
+		PageRef string `json:"pageref"`
+		Request struct {
+			Method   string         `json:"method"`
+			Url      string         `json:"url"`
+			Headers  []harNameValue `json:"headers"`
+			PostData *struct {
+				MimeType string         `json:"mimeType"`
+				Text     string         `json:"text"`
+				Params   []harNameValue `json:"params"`
+			} `json:"postData"`
+		} `json:"request"`
+	}
+
+	harNameValue struct {
+		Name        string `json:"name"`
+		Value       string `json:"value"`
+		FileName    string `json:"fileName"`
+		ContentType string `json:"contentType"`
+	}
+)
+
+// the headers the browser (or the transport) takes care of
+var harSkippedHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"connection":        true,
+	"accept-encoding":   true,
+	"transfer-encoding": true,
+}
+
+func importHar(data []byte) *importedScript {
+	var archive harArchive
+	quitOnError(json.Unmarshal(data, &archive), "Parsing HAR [%s]", filename())
+
+	titles := map[string]string{}
+	for _, page := range archive.Log.Pages {
+		titles[page.Id] = page.Title
+	}
+
+	script := &importedScript{source: fmt.Sprintf("HAR created by %s %s", archive.Log.Creator.Name, archive.Log.Creator.Version)}
+	for _, entry := range archive.Log.Entries {
+		request := &importedRequest{
+			section: titles[entry.PageRef],
+			method:  strings.ToUpper(entry.Request.Method),
+			url:     entry.Request.Url,
+		}
+
+		multipart := false
+		for _, header := range entry.Request.Headers {
+			name := lower(header.Name)
+			switch {
+			case strings.HasPrefix(name, ":"), harSkippedHeaders[name]:
+				// HTTP/2 pseudo headers and the ones set by the transport
+			case name == lower(headerContentType) && strings.HasPrefix(lower(header.Value), "multipart/"):
+				// the boundary is going to be different
+				multipart = true
+			default:
+				request.headers = append(request.headers, [2]string{header.Name, header.Value})
+			}
+		}
+
+		if post := entry.Request.PostData; post != nil {
+			mimeType := lower(post.MimeType)
+			switch {
+			case strings.HasPrefix(mimeType, "multipart/form-data") || multipart:
+				form := &formBody{multipart: true}
+				for _, param := range post.Params {
+					field := formField{name: param.Name, value: param.Value}
+					if len(param.FileName) > 0 {
+						field.file, field.value, field.contentType = true, param.FileName, param.ContentType
+						request.note("the content of the file [%s] is not in the archive", param.FileName)
+					}
+					form.fields = append(form.fields, field)
+				}
+				if len(post.Params) == 0 && len(post.Text) > 0 {
+					request.note("the multipart body is not broken into parts in the archive")
+				}
+				request.form = form
+				request.headers = withoutHeader(request.headers, headerContentType)
+			case strings.HasPrefix(mimeType, contentTypeForm) && len(post.Params) > 0:
+				form := &formBody{}
+				for _, param := range post.Params {
+					form.fields = append(form.fields, formField{name: param.Name, value: param.Value})
+				}
+				request.form = form
+				request.headers = withoutHeader(request.headers, headerContentType)
+			default:
+				request.body = post.Text
+			}
+		}
+		script.requests = append(script.requests, request)
+	}
+	return script
+}
+
+func withoutHeader(headers [][2]string, name string) [][2]string {
+	result := [][2]string{}
+	for _, header := range headers {
+		if lower(header[0]) != lower(name) {
+			result = append(result, header)
+		}
+	}
+	return result
+}
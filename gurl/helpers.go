@@ -22,17 +22,40 @@ func help(txt string) bool {
 
 func usage() {
 	color.Set(colorUsage)
-	fmt.Println("Usage: gurl script.gurl")
+	fmt.Println("Usage: gurl [options] script.gurl")
+	fmt.Println("       gurl export --format=curl|httpie|http|postman [--output file] script.gurl")
+	fmt.Println("       gurl import [--format=postman|har|curl] [--environment env.json] [--output script.gurl] source")
+	fmt.Println("       gurl generate --openapi api.yaml [--by tag|operation] [--output dir]")
+	fmt.Println("       gurl serve [--port 8080] mocks.gurl")
+	fmt.Println("       gurl settings [-env name] [-D key=value] [script.gurl]")
+	fmt.Println("       gurl test [-parallel 4] [-run section] [-tags smoke,!slow] ./dir/... [script.gurl]")
+	fmt.Println("Options:")
+	fmt.Println("  -silent               suppress the progress output")
+	fmt.Println("  -debug                print debug information")
+	fmt.Println("  -curl                 generate curl commands instead of making requests")
+	fmt.Println("  -report junit[=file]  keep going after failed REQUIREs and produce a JUnit report")
+	fmt.Println("  -report tap[=file]    keep going after failed REQUIREs and produce a TAP report")
+	fmt.Println("  -history file.json    save all the request/response exchanges at the end of the run")
+	fmt.Println("  -session file.json    load the cookies and variables before and save them after the run")
+	fmt.Println("  -record file.json     record the request/response exchanges into a cassette")
+	fmt.Println("  -replay file.json     serve the responses from a cassette instead of the network")
+	fmt.Println("  -section name         run the preamble and the matching SECTIONs only")
+	fmt.Println("  -env name             use the environment from gurl.environments.yaml (and gurl.secrets.yaml)")
+	fmt.Println("  -secrets file.yaml    read the secrets from the given file")
+	fmt.Println("  -D key=value          override a setting (or set a variable), wins over everything else")
+	fmt.Println("  -load users=10,duration=30s[,rampup=5s][,section=name][,format=json]")
+	fmt.Println("                        run the script concurrently and report the latency percentiles")
 	fmt.Println(versionInfo)
 	color.Unset()
 
-	os.Exit(exitCodeOnUsage)
+	exit(exitCodeOnUsage)
 }
 
 func quitOnError(err error, format string, a ...interface{}) {
 	if err != nil {
 		reportError(err, format, a...)
-		os.Exit(exitCodeOnError)
+		recordError(fmt.Sprintf(format, a...) + ": " + err.Error())
+		exit(exitCodeOnError)
 	}
 }
 
@@ -43,9 +66,17 @@ func reportError(err error, format string, a ...interface{}) {
 	_, _ = fmt.Fprintf(os.Stderr, "Got an error: %v, while ", err)
 	_, _ = fmt.Fprintf(os.Stderr, format+"\n", a...)
 
+	reportLocation()
+}
+
+func reportLocation() {
 	if len(currentFile) > 0 {
 		_, _ = fmt.Fprintf(os.Stderr, "(script: [%s], line: %v)\n", currentFile, currentLineNumber)
 	}
+	for index := len(callStack) - 1; index >= 0; index-- {
+		caller := callStack[index]
+		_, _ = fmt.Fprintf(os.Stderr, "(%s from script: [%s], line: %v)\n", caller.reason, caller.file, caller.line)
+	}
 
 	if len(currentCommand) > 0 {
 		_, _ = fmt.Fprintf(os.Stderr, "(command: [%s])\n", currentCommand)
@@ -54,7 +85,23 @@ func reportError(err error, format string, a ...interface{}) {
 
 func quit(format string, a ...interface{}) {
 	_, _ = fmt.Fprintf(os.Stderr, "Got an error while "+format+"\n", a...)
-	os.Exit(exitCodeOnError)
+	if len(callStack) > 0 {
+		reportLocation()
+	}
+	recordError(fmt.Sprintf(format, a...))
+	exit(exitCodeOnError)
+}
+
+// exit runs all the registered exit handlers before terminating the process
+func exit(code int) {
+	for _, handler := range exitHandlers {
+		handler()
+	}
+	os.Exit(code)
+}
+
+func onExit(handler func()) {
+	exitHandlers = append(exitHandlers, handler)
 }
 
 func comment(allow bool, format string, a ...interface{}) {
@@ -112,7 +159,7 @@ func colorPrint(clr interface{}, format string, a ...interface{}) {
 }
 
 func filename() string {
-	return os.Args[1]
+	return scriptName
 }
 
 func niy() {
@@ -138,7 +185,7 @@ func splitBy(src, separator string) (string, string) {
 
 func multiLineCommand(cmd string) bool {
 	switch lower(cmd) {
-	case "post", "get", "put", "patch", "delete":
+	case "post", "get", "put", "patch", "delete", "head", "options", "request":
 		return true
 	}
 	return false
@@ -181,7 +228,7 @@ func lower(src string) string {
 }
 
 func generate(format string, a ...interface{}) {
-	if offline() {
+	if generateCurlCommands {
 		_, _ = fmt.Fprintf(os.Stdout, ""+format+"\n", a...)
 	}
 }
@@ -0,0 +1,89 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+// GET:login /auth
+// Header Authorization: Bearer ${login.response:token}
+// Require ${login.status} 200
+// Echo ${login.header:Set-Cookie}
+
+var (
+	history       = map[string]*exchange{}
+	exchanges     = []*exchange{}
+	historyFile   = ""
+	keepExchanges = false
+)
+
+func remember(label string, what *exchange) {
+	what.label = label
+	if len(label) > 0 {
+		if _, found := history[label]; found {
+			debug("overriding history entry [%s]", label)
+		}
+		history[label] = what
+	}
+	if keepExchanges {
+		exchanges = append(exchanges, what)
+	}
+}
+
+func enableHistoryDump(file string) {
+	historyFile = file
+	keepExchanges = true
+	onExit(dumpHistory)
+}
+
+type historyEntry struct {
+	Label  string             `json:"label,omitempty"`
+	Method string             `json:"method"`
+	Url    string             `json:"url"`
+	Status int                `json:"status"`
+	Header http.Header        `json:"headers,omitempty"`
+	Body   interface{}        `json:"body,omitempty"`
+	Timing map[string]float64 `json:"timing,omitempty"`
+}
+
+func dumpHistory() {
+	entries := make([]historyEntry, 0, len(exchanges))
+	for _, one := range exchanges {
+		entry := historyEntry{
+			Label:  one.label,
+			Method: one.method,
+			Url:    one.url,
+			Status: one.status,
+			Header: one.header,
+			Timing: make(map[string]float64),
+		}
+		if len(one.body) > 0 {
+			var body interface{}
+			if err := json.Unmarshal(one.body, &body); err == nil {
+				entry.Body = body
+			} else {
+				entry.Body = string(one.body)
+			}
+		}
+		for phase, duration := range one.timing {
+			entry.Timing[phase] = float64(duration.Microseconds()) / 1000
+		}
+		entries = append(entries, entry)
+	}
+
+	data, err := json.MarshalIndent(entries, marshalPrefix, marshalIndent)
+	if err != nil {
+		reportError(err, "Marshalling history")
+		return
+	}
+	if err := ioutil.WriteFile(historyFile, data, 0644); err != nil {
+		reportError(err, "Saving history to [%s]", historyFile)
+		return
+	}
+	report("history of %v exchanges saved to %s", len(entries), historyFile)
+}
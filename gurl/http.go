@@ -19,23 +19,52 @@ import (
 	"github.com/seamia/libs/printer"
 )
 
-func call(relativeUrl, verb, data string) {
+func call(relativeUrl, verb, data, options string) {
+	settings := parseRequestOptions(options)
 
 	u, err := url.Parse(expand(baseUrl))
 	quitOnError(err, "Parsing url [%s]", baseUrl)
-	u.Path = path.Join(u.Path, expand(relativeUrl))
+	relative := expand(relativeUrl)
+	if query := strings.Index(relative, "?"); query >= 0 {
+		// the query goes as it is: path.Join would escape it as a part of the path
+		// (GET /users?page=2 went out as /users%3Fpage=2), and it is added to the query of base.url
+		if len(u.RawQuery) > 0 {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += relative[query+1:]
+		relative = relative[:query]
+	}
+	u.Path = path.Join(u.Path, relative)
 	fullUrl := u.String()
 
-	if generateCurlCommands {
-		produceCurlCommand(fullUrl, verb, data)
+	// the payload comes unexpanded, so that the values of a form cannot break its fields apart
+	form := parseFormBody(data)
+	if form != nil {
+		// export and -curl take the fields as they are (the files are not read),
+		// and the tools build the form body (and sign it) themselves
+		data = ""
+	}
+	data = expand(data)
+
+	if exporting {
+		exportRequest(fullUrl, verb, data, form, settings)
+	} else if generateCurlCommands {
+		produceCurlCommand(fullUrl, verb, data, form, authHeaders(verb, fullUrl, data), settings.save)
 	} else {
-		data = loadExternalFile(data)
+		contentType := ""
+		if form != nil {
+			var encoded []byte
+			contentType, encoded = form.encode()
+			data = string(encoded)
+		} else {
+			data = loadExternalFile(data)
+		}
 		var payload io.Reader
 		if len(data) > 0 {
 			payload = bytes.NewReader([]byte(data))
 		}
 
-		client := &http.Client{}
+		client := httpClient()
 		request, err := http.NewRequest(strings.ToUpper(verb), fullUrl, payload)
 
 		quitOnError(err, "...")
@@ -45,7 +74,15 @@ func call(relativeUrl, verb, data string) {
 				request.Header.Set(key, expand(value))
 			}
 		}
+		if len(contentType) > 0 {
+			request.Header.Set(headerContentType, contentType)
+		}
 		request.Header.Set("User-Agent", userAgent)
+		if currentAuth != nil {
+			currentAuth.authenticate(request, []byte(data))
+		}
+
+		request, collected := traceTiming(request)
 
 		start := time.Now()
 		resp, err := client.Do(request)
@@ -53,15 +90,40 @@ func call(relativeUrl, verb, data string) {
 			duration := time.Now().Sub(start)
 			response("the request took %s", duration.String())
 		}
+		if err != nil && loadWorker {
+			recordSample(sampleLabel(settings, verb), time.Since(start), 0, err)
+			return
+		}
 		quitOnError(err, "......")
 
-		displayResponse(resp)
+		var body []byte
+		var saved *savedBody
+		if resp.Body != nil {
+			if len(settings.save) > 0 {
+				saved = saveBody(resp, settings.save)
+			} else {
+				body, err = ioutil.ReadAll(resp.Body)
+			}
+			_ = resp.Body.Close()
+			quitOnError(err, "Ingesting response body")
+		}
+		timing := collected()
+		timing[timingTotal] = time.Since(start)
+		if loadWorker {
+			recordSample(sampleLabel(settings, verb), timing[timingTotal], resp.StatusCode, err)
+		}
+
+		lastExchange = newExchange(request, resp, body, timing)
+		remember(settings.label, lastExchange)
+		displayResponse(resp, body, saved, settings.noBody)
+		validateExchange(lastExchange, saved == nil)
 	}
 }
 
-func displayResponse(resp *http.Response) {
+func displayResponse(resp *http.Response, data []byte, saved *savedBody, noBody bool) {
 	if resp == nil {
 		response("got an empty response")
+		return
 	}
 
 	print := responseFailure
@@ -74,19 +136,16 @@ func displayResponse(resp *http.Response) {
 	print("Status: %s", resp.Status)
 	displayHeaders(resp, print)
 
-	if resp.Body != nil {
-		data, err := ioutil.ReadAll(resp.Body)
-		quitOnError(err, "Ingesting response body")
-		savedResponse = data
-
-		switch getContentType(resp) {
-		case contentTypeJson:
-			displayJsonBody(data, print)
-		default:
-			displayPlainBody(data, print)
-		}
-	} else {
-		savedResponse = nil
+	switch contentType := getContentType(resp); {
+	case saved != nil:
+		print("Body saved to [%s]: %s, sha256: %s", saved.path, humanSize(saved.size), saved.sha256)
+	case noBody || !printResponseBody:
+	case contentType == contentTypeJson:
+		displayJsonBody(data, print)
+	case isBinary(contentType, data):
+		displayBinaryBody(data, contentType, print)
+	default:
+		displayPlainBody(data, print)
 	}
 	// saveResponse(resp)
 }
@@ -157,16 +216,22 @@ func attentionNeeded(key string) bool {
 	return false
 }
 
+// SavedResponse is an exchange as kept in a cassette (see cassette.go)
 type SavedResponse struct {
 	Response struct {
 		Status     string      `json:"status"`
 		StatusCode int         `json:"status-code"`
 		Header     http.Header `json:"headers"`
-	} `json:""`
+		Body       string      `json:"body,omitempty"`
+		Encoding   string      `json:"encoding,omitempty"`
+	} `json:"response"`
 	Request struct {
-		Url    string `json:"url"`
-		Method string `json:"method"`
-	} `json:""`
+		Url      string      `json:"url"`
+		Method   string      `json:"method"`
+		Header   http.Header `json:"headers,omitempty"`
+		Body     string      `json:"body,omitempty"`
+		Encoding string      `json:"encoding,omitempty"`
+	} `json:"request"`
 }
 
 func saveResponse(resp *http.Response) {
@@ -0,0 +1,327 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// gurl import [--format=postman|har|curl] [--environment env.json] [--output script.gurl] source
+//
+// the source is converted into the common form (below) first, which is then written out as a script:
+// the variables become MAPs, the headers shared by all the requests become HEADERs at the top,
+// the others are set (and removed) around the requests that use them. Whatever cannot be
+// converted is flagged with a comment.
+
+type (
+	importedScript struct {
+		source    string
+		settings  []string
+		variables [][2]string
+		requests  []*importedRequest
+	}
+
+	importedRequest struct {
+		section string
+		name    string
+		method  string
+		url     string
+		headers [][2]string
+		auth    string
+		body    string
+		form    *formBody
+		notes   []string
+	}
+
+	importer func(data []byte) *importedScript
+)
+
+var (
+	importOtherSection = "other"
+
+	importers = map[string]importer{
+		"postman": importPostman,
+		"har":     importHar,
+		"curl":    importCurl,
+	}
+
+	originPattern          = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9+.-]*://[^/?#]*|\$\{[^{}]+\})(.*)$`)
+	postmanVariablePattern = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+	// the verbs that have their own commands (the others go through REQUEST)
+	importVerbs = map[string]bool{
+		http.MethodGet:     true,
+		http.MethodPost:    true,
+		http.MethodPut:     true,
+		http.MethodPatch:   true,
+		http.MethodDelete:  true,
+		http.MethodHead:    true,
+		http.MethodOptions: true,
+	}
+)
+
+func runImport() {
+	data, err := ioutil.ReadFile(filename())
+	quitOnError(err, "Opening file %s", filename())
+
+	format, _ := optionValue("--format", "-format")
+	if len(format) == 0 {
+		format = detectImportFormat(data)
+	}
+	convert, found := importers[lower(format)]
+	if !found {
+		quit("import requires --format=postman, har or curl, got [%s]", format)
+	}
+
+	script := convert(data)
+	if environment, found := optionValue("--environment", "-environment"); found && len(environment) > 0 {
+		script.variables = append(script.variables, importPostmanEnvironment(environment)...)
+	}
+
+	result := script.write()
+	if output, found := optionValue("--output", "-output", "-o"); found && len(output) > 0 {
+		quitOnError(ioutil.WriteFile(output, result, 0644), "Writing [%s]", output)
+	} else {
+		_, _ = os.Stdout.Write(result)
+	}
+	exit(exitCodeOnSuccess)
+}
+
+func detectImportFormat(data []byte) string {
+	var probe struct {
+		Info *json.RawMessage `json:"info"`
+		Item *json.RawMessage `json:"item"`
+		Log  *json.RawMessage `json:"log"`
+	}
+	if err := json.Unmarshal(data, &probe); err == nil {
+		switch {
+		case probe.Log != nil:
+			return "har"
+		case probe.Info != nil || probe.Item != nil:
+			return "postman"
+		}
+	}
+	for _, words := range shellCommands(string(data)) {
+		if words[0] == "curl" {
+			return "curl"
+		}
+	}
+	quit("cannot figure out the format of [%s], use --format=postman, har or curl", filename())
+	return ""
+}
+
+// postmanVariables converts {{name}} into ${name}
+func postmanVariables(src string) string {
+	return postmanVariablePattern.ReplaceAllStringFunc(src, func(variable string) string {
+		name := strings.TrimSpace(variable[2 : len(variable)-2])
+		if name == "$guid" || name == "$randomUUID" {
+			return "${random}"
+		}
+		return "${" + name + "}"
+	})
+}
+
+func (request *importedRequest) note(format string, a ...interface{}) {
+	request.notes = append(request.notes, fmt.Sprintf(format, a...))
+}
+
+// write produces the text of the script
+func (script *importedScript) write() []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# imported from %s\n", script.source)
+	for _, setting := range script.settings {
+		fmt.Fprintln(&out, setting)
+	}
+	for _, variable := range script.variables {
+		fmt.Fprintf(&out, "MAP %s %s\n", variable[0], variable[1])
+	}
+
+	for _, request := range script.requests {
+		headers := [][2]string{}
+		for _, header := range request.headers {
+			if strings.Contains(header[0]+header[1], commentPrefix) {
+				request.note("the header [%s] contains '#', which starts a comment in a script", header[0])
+				continue
+			}
+			headers = append(headers, header)
+		}
+		request.headers = headers
+	}
+
+	common := script.commonHeaders()
+	for _, header := range common {
+		fmt.Fprintf(&out, "HEADER %s %s\n", header[0], header[1])
+	}
+
+	// the headers in effect (by their lower case name)
+	active := map[string][2]string{}
+	for _, header := range common {
+		active[lower(header[0])] = header
+	}
+	base, auth, section := "", "", ""
+	for _, request := range script.requests {
+		fmt.Fprintln(&out)
+		if request.section != section {
+			section = request.section
+			name := section
+			if len(name) == 0 {
+				// back from a folder/page
+				name = importOtherSection
+			}
+			fmt.Fprintf(&out, "SECTION %s\n\n", name)
+		}
+		if len(request.name) > 0 {
+			fmt.Fprintf(&out, "# %s\n", request.name)
+		}
+
+		origin, target := request.url, ""
+		if match := originPattern.FindStringSubmatch(request.url); match != nil {
+			origin, target = match[1], match[2]
+		} else {
+			request.note("the url [%s] has no scheme and host", request.url)
+		}
+		if strings.Contains(target, "#") {
+			target = target[:strings.Index(target, "#")]
+			request.note("the fragment of the url is dropped")
+		}
+		if len(target) == 0 {
+			target = "/"
+		}
+
+		body := request.body
+		switch {
+		case len(body) == 0:
+		case request.method == http.MethodGet:
+			request.note("the body of a GET request is not supported: %s", oneLine(body))
+			body = ""
+		case strings.Contains(body, commentPrefix):
+			request.note("the body contains '#', which starts a comment in a script: save it into a file and use @file")
+			body = ""
+		default:
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, []byte(body)); err == nil {
+				body = compact.String()
+			} else if strings.Contains(body, "\n") {
+				body = oneLine(body)
+				request.note("the line breaks in the body are replaced with spaces")
+			}
+		}
+
+		for _, note := range request.notes {
+			fmt.Fprintf(&out, "# note: %s\n", note)
+		}
+		if origin != base {
+			base = origin
+			fmt.Fprintf(&out, "SET BaseUrl %s\n", base)
+		}
+		if request.auth != auth {
+			auth = request.auth
+			if len(auth) == 0 {
+				fmt.Fprintln(&out, "AUTH none")
+			} else {
+				fmt.Fprintln(&out, auth)
+			}
+		}
+
+		wanted := map[string]bool{}
+		for _, header := range append(request.headers, common...) {
+			wanted[lower(header[0])] = true
+		}
+		for _, name := range sortedNames(active) {
+			if !wanted[name] {
+				fmt.Fprintf(&out, "HEADER %s\n", active[name][0])
+				delete(active, name)
+			}
+		}
+		for _, header := range request.headers {
+			if current, found := active[lower(header[0])]; !found || current != header {
+				if found && current[0] != header[0] {
+					fmt.Fprintf(&out, "HEADER %s\n", current[0])
+				}
+				fmt.Fprintf(&out, "HEADER %s %s\n", header[0], header[1])
+				active[lower(header[0])] = header
+			}
+		}
+
+		command := request.method
+		if !importVerbs[request.method] {
+			command = "REQUEST " + request.method
+		}
+		fmt.Fprintf(&out, "%s %s\n", command, target)
+		switch {
+		case request.form != nil:
+			fields := request.form.fields
+			if request.form.multipart {
+				fmt.Fprintln(&out, "MULTIPART")
+			} else {
+				fmt.Fprintln(&out, "FORM")
+			}
+			for _, field := range fields {
+				value := field.value
+				if field.file {
+					value = externalFilePrefix + value
+					if len(field.contentType) > 0 {
+						value += ";type=" + field.contentType
+					}
+				}
+				fmt.Fprintf(&out, "%s=%s\n", field.name, oneLine(value))
+			}
+		case len(body) > 0:
+			fmt.Fprintln(&out, body)
+		}
+	}
+	return out.Bytes()
+}
+
+// commonHeaders returns the headers all the requests have (with the same value)
+func (script *importedScript) commonHeaders() [][2]string {
+	if len(script.requests) < 2 {
+		return nil
+	}
+	common := [][2]string{}
+	for _, candidate := range script.requests[0].headers {
+		shared := true
+		for _, request := range script.requests[1:] {
+			if !request.hasHeader(candidate) {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			common = append(common, candidate)
+		}
+	}
+	return common
+}
+
+func (request *importedRequest) hasHeader(header [2]string) bool {
+	for _, one := range request.headers {
+		if lower(one[0]) == lower(header[0]) && one[1] == header[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNames(src map[string][2]string) []string {
+	names := make([]string, 0, len(src))
+	for name := range src {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func oneLine(src string) string {
+	return strings.Join(strings.Fields(src), " ")
+}
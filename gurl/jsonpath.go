@@ -0,0 +1,422 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+// ${response:$.items[0].id}                      JSONPath
+// ${response:$.items[-1]}                        (from the end)
+// ${response:$['odd name'].items[*].id}          (the wildcards, slices [0:2], unions [0,2], ..name and
+// ${response:$..items[?(@.age >= 30)].name}       the filters give an array of whatever matched)
+// ${response:jmes:items[?age > `30`].name}       JMESPath
+//
+// the old path syntax (items/:first/id) keeps working alongside.
+
+const (
+	jsonPathRoot = "$"
+	jmesPrefix   = "jmes:"
+)
+
+type (
+	jsonPathStep struct {
+		recursive bool
+		wildcard  bool
+		names     []string
+		indexes   []int
+		slice     *[2]*int
+		filter    *jsonPathFilter
+	}
+
+	jsonPathFilter struct {
+		path     string
+		operator string
+		literal  interface{}
+	}
+)
+
+var jsonPathOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func isPathExpression(key string) bool {
+	return isJsonPath(key) || strings.HasPrefix(lower(key), jmesPrefix)
+}
+
+// isJsonPath tells $, $.items and $[0] from the names starting with $ ($schema, _id/$oid)
+func isJsonPath(key string) bool {
+	return key == jsonPathRoot || strings.HasPrefix(key, jsonPathRoot+".") || strings.HasPrefix(key, jsonPathRoot+"[")
+}
+
+// lookupExpression evaluates the JSONPath or JMESPath expression
+func lookupExpression(src interface{}, key string) (bool, interface{}) {
+	if strings.HasPrefix(lower(key), jmesPrefix) {
+		result, err := jmespath.Search(key[len(jmesPrefix):], src)
+		if err != nil {
+			reportError(err, "evaluating JMESPath [%s]", key[len(jmesPrefix):])
+			return false, nil
+		}
+		return result != nil, result
+	}
+
+	steps, err := parseJsonPath(key)
+	if err != nil {
+		reportError(err, "parsing JSONPath [%s]", key)
+		return false, nil
+	}
+	return evaluateJsonPath(src, steps)
+}
+
+func parseJsonPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, jsonPathRoot) {
+		return nil, fmt.Errorf("JSONPath starts with $")
+	}
+	steps := []jsonPathStep{}
+	rest := path[len(jsonPathRoot):]
+	for len(rest) > 0 {
+		step := jsonPathStep{}
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			step.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				break
+			}
+			fallthrough
+		case strings.HasPrefix(rest, "."):
+			rest = strings.TrimPrefix(rest, ".")
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			rest = rest[end:]
+			if len(name) == 0 {
+				return nil, fmt.Errorf("empty name in the path")
+			}
+			if name == "*" {
+				step.wildcard = true
+			} else {
+				step.names = []string{name}
+			}
+			steps = append(steps, step)
+			continue
+		}
+
+		if !strings.HasPrefix(rest, "[") {
+			return nil, fmt.Errorf("unexpected [%s]", rest)
+		}
+		end := closingBracket(rest)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated [ in [%s]", rest)
+		}
+		if err := step.parseBracket(strings.TrimSpace(rest[1:end])); err != nil {
+			return nil, err
+		}
+		rest = rest[end+1:]
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// closingBracket returns the index of the ] matching the leading [ (skipping the quoted text)
+func closingBracket(src string) int {
+	depth := 0
+	var quote byte
+	for at := 0; at < len(src); at++ {
+		switch char := src[at]; {
+		case quote != 0:
+			if char == '\\' {
+				at++
+			} else if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"':
+			quote = char
+		case char == '[':
+			depth++
+		case char == ']':
+			depth--
+			if depth == 0 {
+				return at
+			}
+		}
+	}
+	return -1
+}
+
+func (step *jsonPathStep) parseBracket(inside string) error {
+	switch {
+	case inside == "*":
+		step.wildcard = true
+	case strings.HasPrefix(inside, "?"):
+		filter, err := parseJsonPathFilter(strings.TrimSpace(inside[1:]))
+		if err != nil {
+			return err
+		}
+		step.filter = filter
+	case strings.HasPrefix(inside, "'") || strings.HasPrefix(inside, `"`):
+		for _, part := range splitOutsideQuotes(inside, ',') {
+			name, err := unquoteJsonPath(strings.TrimSpace(part))
+			if err != nil {
+				return err
+			}
+			step.names = append(step.names, name)
+		}
+	case strings.Contains(inside, ":"):
+		bounds := [2]*int{}
+		for at, part := range strings.SplitN(inside, ":", 3)[:2] {
+			if part = strings.TrimSpace(part); len(part) > 0 {
+				value, err := strconv.Atoi(part)
+				if err != nil {
+					return fmt.Errorf("wrong slice [%s]", inside)
+				}
+				bounds[at] = &value
+			}
+		}
+		step.slice = &bounds
+	default:
+		for _, part := range strings.Split(inside, ",") {
+			index, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return fmt.Errorf("wrong index [%s]", part)
+			}
+			step.indexes = append(step.indexes, index)
+		}
+	}
+	return nil
+}
+
+// ?(@.age >= 30), ?(@.name == 'gurl'), ?(@.email)
+func parseJsonPathFilter(src string) (*jsonPathFilter, error) {
+	if !strings.HasPrefix(src, "(") || !strings.HasSuffix(src, ")") {
+		return nil, fmt.Errorf("filter is expected to be ?(...), got [%s]", src)
+	}
+	src = strings.TrimSpace(src[1 : len(src)-1])
+	if !strings.HasPrefix(src, "@") {
+		return nil, fmt.Errorf("filter is expected to start with @, got [%s]", src)
+	}
+
+	filter := &jsonPathFilter{path: src[1:]}
+	for _, operator := range jsonPathOperators {
+		if at := strings.Index(src, operator); at > 0 {
+			filter.path, filter.operator = strings.TrimSpace(src[1:at]), operator
+			raw := strings.TrimSpace(src[at+len(operator):])
+			if strings.HasPrefix(raw, "'") {
+				text, err := unquoteJsonPath(raw)
+				if err != nil {
+					return nil, err
+				}
+				filter.literal = text
+			} else if err := json.Unmarshal([]byte(raw), &filter.literal); err != nil {
+				return nil, fmt.Errorf("wrong value [%s] in the filter", raw)
+			}
+			break
+		}
+	}
+	return filter, nil
+}
+
+func unquoteJsonPath(src string) (string, error) {
+	if len(src) < 2 || (src[0] != '\'' && src[0] != '"') || src[len(src)-1] != src[0] {
+		return "", fmt.Errorf("wrong quoted name [%s]", src)
+	}
+	inside := src[1 : len(src)-1]
+	if src[0] == '\'' {
+		inside = strings.NewReplacer(`\'`, `'`, `"`, `\"`).Replace(inside)
+	}
+	return strconv.Unquote(`"` + inside + `"`)
+}
+
+func splitOutsideQuotes(src string, separator byte) []string {
+	parts := []string{}
+	var quote byte
+	start := 0
+	for at := 0; at < len(src); at++ {
+		switch char := src[at]; {
+		case quote != 0:
+			if char == '\\' {
+				at++
+			} else if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"':
+			quote = char
+		case char == separator:
+			parts = append(parts, src[start:at])
+			start = at + 1
+		}
+	}
+	return append(parts, src[start:])
+}
+
+// evaluateJsonPath returns the value of a definite path, or the array of the matches
+func evaluateJsonPath(src interface{}, steps []jsonPathStep) (bool, interface{}) {
+	nodes := []interface{}{src}
+	definite := true
+	for _, step := range steps {
+		definite = definite && step.definite()
+		matched := []interface{}{}
+		for _, node := range nodes {
+			candidates := []interface{}{node}
+			if step.recursive {
+				candidates = descendants(node, candidates)
+			}
+			for _, candidate := range candidates {
+				matched = append(matched, step.apply(candidate)...)
+			}
+		}
+		nodes = matched
+	}
+
+	if !definite {
+		return true, slice(nodes)
+	}
+	if len(nodes) != 1 {
+		return false, nil
+	}
+	return true, nodes[0]
+}
+
+func (step *jsonPathStep) definite() bool {
+	return !step.recursive && !step.wildcard && step.slice == nil && step.filter == nil &&
+		len(step.names)+len(step.indexes) == 1
+}
+
+func (step *jsonPathStep) apply(node interface{}) []interface{} {
+	result := []interface{}{}
+	switch actual := node.(type) {
+	case msi:
+		switch {
+		case step.wildcard:
+			for _, key := range sortedKeys(actual) {
+				result = append(result, actual[key])
+			}
+		case step.filter != nil:
+			for _, key := range sortedKeys(actual) {
+				if step.filter.matches(actual[key]) {
+					result = append(result, actual[key])
+				}
+			}
+		default:
+			for _, name := range step.names {
+				if value, found := actual[name]; found {
+					result = append(result, value)
+				}
+			}
+		}
+
+	case slice:
+		switch {
+		case step.wildcard:
+			result = append(result, actual...)
+		case step.filter != nil:
+			for _, item := range actual {
+				if step.filter.matches(item) {
+					result = append(result, item)
+				}
+			}
+		case step.slice != nil:
+			start, end := 0, len(actual)
+			if step.slice[0] != nil {
+				start = boundedIndex(*step.slice[0], len(actual))
+			}
+			if step.slice[1] != nil {
+				end = boundedIndex(*step.slice[1], len(actual))
+			}
+			for at := start; at < end; at++ {
+				result = append(result, actual[at])
+			}
+		default:
+			for _, index := range step.indexes {
+				if index < 0 {
+					index += len(actual)
+				}
+				if index >= 0 && index < len(actual) {
+					result = append(result, actual[index])
+				}
+			}
+		}
+	}
+	return result
+}
+
+func boundedIndex(index, length int) int {
+	if index < 0 {
+		index += length
+	}
+	if index < 0 {
+		return 0
+	}
+	if index > length {
+		return length
+	}
+	return index
+}
+
+// descendants appends all the values nested in the node (depth first)
+func descendants(node interface{}, result []interface{}) []interface{} {
+	switch actual := node.(type) {
+	case msi:
+		for _, key := range sortedKeys(actual) {
+			result = descendants(actual[key], append(result, actual[key]))
+		}
+	case slice:
+		for _, item := range actual {
+			result = descendants(item, append(result, item))
+		}
+	}
+	return result
+}
+
+func (filter *jsonPathFilter) matches(item interface{}) bool {
+	found, value := true, item
+	if len(filter.path) > 0 {
+		steps, err := parseJsonPath(jsonPathRoot + filter.path)
+		if err != nil {
+			return false
+		}
+		found, value = evaluateJsonPath(item, steps)
+	}
+	if len(filter.operator) == 0 {
+		return found
+	}
+	if !found {
+		return false
+	}
+
+	left, leftIsNumber := value.(float64)
+	right, rightIsNumber := filter.literal.(float64)
+	if !leftIsNumber || !rightIsNumber {
+		switch filter.operator {
+		case "==":
+			return valueToText(value) == valueToText(filter.literal)
+		case "!=":
+			return valueToText(value) != valueToText(filter.literal)
+		}
+		leftText, rightText := valueToText(value), valueToText(filter.literal)
+		left, right = float64(strings.Compare(leftText, rightText)), 0
+	}
+
+	switch filter.operator {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	case ">=":
+		return left >= right
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const jsonPathDocument = `{
+	"count": 3,
+	"ok": true,
+	"nothing": null,
+	"odd name": "odd",
+	"$schema": "s",
+	"_id": {"$oid": "abc"},
+	"items": [
+		{"id": "a1", "age": 25, "tags": ["x"]},
+		{"id": "b2", "age": 31},
+		{"id": "c3", "age": 40, "email": "c3@example.com"}
+	]
+}`
+
+func TestJsonPath(t *testing.T) {
+	var document interface{}
+	if err := json.Unmarshal([]byte(jsonPathDocument), &document); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"$.items[0].id":                        "a1",
+		"$.items[-1].id":                       "c3",
+		"$['odd name']":                        "odd",
+		"$.count":                              "3",
+		"$.ok":                                 "true",
+		"$.nothing":                            "null",
+		"$.items[0].tags":                      `["x"]`,
+		"$.items[*].id":                        `["a1","b2","c3"]`,
+		"$.items[0:2].id":                      `["a1","b2"]`,
+		"$.items[0,2].id":                      `["a1","c3"]`,
+		"$..email":                             `["c3@example.com"]`,
+		"$.items[?(@.age >= 31)].id":           `["b2","c3"]`,
+		"$.items[?(@.id == 'b2')].age":         `[31]`,
+		"$.items[?(@.email)].id":               `["c3"]`,
+		"jmes:items[?age > `30`].id":           `["b2","c3"]`,
+		"jmes:length(items)":                   "3",
+		"items/1/id":                           "b2",
+		"$schema":                              "s",
+		"_id/$oid":                             "abc",
+		"items/-1/age":                         "40",
+		"items/id:first/id":                    "a1",
+		"items/id==c3;id:first/age":            "40",
+		"items":                                `[{"age":25,"id":"a1","tags":["x"]},{"age":31,"id":"b2"},{"age":40,"email":"c3@example.com","id":"c3"}]`,
+		"$.items[1]":                           `{"age":31,"id":"b2"}`,
+		"jmes:items[0].{name: id, years: age}": `{"name":"a1","years":25}`,
+	}
+	for key, want := range expected {
+		found, got := resolveAny(document, key)
+		if !found {
+			t.Fatalf("[%s] was not found", key)
+		}
+		if got != want {
+			t.Fatalf("[%s] gave [%s], expected [%s]", key, got, want)
+		}
+	}
+
+	for _, key := range []string{"$.missing", "$.items[7].id", "items/7/id", "jmes:missing"} {
+		if found, value := resolveAny(document, key); found {
+			t.Fatalf("[%s] was not expected to be found, got [%s]", key, value)
+		}
+	}
+}
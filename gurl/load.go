@@ -0,0 +1,365 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gurl -load users=50,duration=60s,rampup=10s,section=Checkout,format=json script.gurl
+//
+// every user is a separate gurl process (so each has its own resolver state), which runs
+// the script (or the preamble once and then the chosen section) over and over again,
+// writing a sample per request into its own file. The samples are aggregated at the end.
+
+type (
+	loadSettings struct {
+		users    int
+		duration time.Duration
+		rampUp   time.Duration
+		section  string
+		format   string
+	}
+
+	loadSample struct {
+		Label   string  `json:"label"`
+		Latency float64 `json:"latency,omitempty"`
+		Status  int     `json:"status,omitempty"`
+		Error   string  `json:"error,omitempty"`
+		Check   bool    `json:"check,omitempty"`
+	}
+
+	labelStats struct {
+		Label      string  `json:"label"`
+		Requests   int     `json:"requests"`
+		Errors     int     `json:"errors"`
+		Failures   int     `json:"failed.checks"`
+		ErrorRate  float64 `json:"error.rate"`
+		Throughput float64 `json:"throughput"`
+		P50        float64 `json:"p50"`
+		P90        float64 `json:"p90"`
+		P99        float64 `json:"p99"`
+		Max        float64 `json:"max"`
+
+		latencies []float64
+	}
+)
+
+var (
+	loadSpec = ""
+
+	loadWorker      = false
+	loadSection     = ""
+	workerUntil     time.Time
+	workerOutput    *json.Encoder
+	lastSampleLabel = ""
+)
+
+func enableLoadTest(spec string) {
+	loadSpec = spec
+}
+
+func loadTesting() bool {
+	return len(loadSpec) > 0
+}
+
+func parseLoadSettings(spec string) loadSettings {
+	settings := loadSettings{users: 1, format: "table"}
+	for _, option := range strings.Split(spec, ",") {
+		key, value := splitBy(strings.TrimSpace(option), "=")
+		var err error
+		switch lower(key) {
+		case "users":
+			settings.users, err = strconv.Atoi(value)
+		case "duration":
+			settings.duration, err = time.ParseDuration(value)
+		case "rampup":
+			settings.rampUp, err = time.ParseDuration(value)
+		case "section":
+			settings.section = value
+		case "format":
+			settings.format = lower(value)
+		default:
+			quit("unknown -load option [%s]", option)
+		}
+		quitOnError(err, "Parsing -load option [%s]", option)
+	}
+
+	if settings.users <= 0 || settings.duration <= 0 {
+		quit("-load requires positive users and duration, e.g. -load users=10,duration=30s")
+	}
+	if settings.format != "table" && settings.format != "json" {
+		quit("unknown -load format [%s], expected: table or json", settings.format)
+	}
+	return settings
+}
+
+// runLoadTest starts the workers, waits for them and prints the aggregated results
+func runLoadTest() {
+	settings := parseLoadSettings(loadSpec)
+	if len(recordFile) > 0 {
+		quit("-record cannot be used with -load (all the users would write the same cassette)")
+	}
+
+	executable, err := os.Executable()
+	quitOnError(err, "Locating gurl executable")
+
+	folder, err := ioutil.TempDir("", "gurl-load-")
+	quitOnError(err, "Creating temporary folder")
+	defer os.RemoveAll(folder)
+
+	until := time.Now().Add(settings.rampUp + settings.duration)
+	report("starting %v users for %s against %s", settings.users, settings.duration, filename())
+
+	var wait sync.WaitGroup
+	crashed := make([]string, 0)
+	var lock sync.Mutex
+
+	started := time.Now()
+	for user := 0; user < settings.users; user++ {
+		if settings.rampUp > 0 && user > 0 {
+			time.Sleep(settings.rampUp / time.Duration(settings.users))
+		}
+
+		samples := filepath.Join(folder, fmt.Sprintf("user-%v.json", user))
+		spec := fmt.Sprintf("until=%v,samples=%s", until.UnixNano(), samples)
+		if len(settings.section) > 0 {
+			spec += ",section=" + settings.section
+		}
+		args := append([]string{filename(), "-silent", "-load-worker", spec}, workerOptions()...)
+
+		wait.Add(1)
+		go func(user int) {
+			defer wait.Done()
+			var stderr bytes.Buffer
+			worker := exec.Command(executable, args...)
+			worker.Stderr = &stderr
+			if err := worker.Run(); err != nil {
+				if exitError, converts := err.(*exec.ExitError); !converts || exitError.ExitCode() != exitCodeOnFailure {
+					lock.Lock()
+					crashed = append(crashed, fmt.Sprintf("user %v: %v %s", user, err, strings.TrimSpace(stderr.String())))
+					lock.Unlock()
+				}
+			}
+		}(user)
+	}
+	wait.Wait()
+	elapsed := time.Since(started)
+
+	for _, one := range crashed {
+		responseFailure("worker failed: %s", one)
+	}
+
+	stats := aggregateSamples(folder, elapsed)
+	switch settings.format {
+	case "json":
+		data, err := json.MarshalIndent(stats, marshalPrefix, marshalIndent)
+		quitOnError(err, "Marshalling results")
+		fmt.Println(string(data))
+	default:
+		printLoadTable(stats, elapsed)
+	}
+
+	for _, one := range stats {
+		if one.Errors > 0 || one.Failures > 0 {
+			exit(exitCodeOnFailure)
+		}
+	}
+	if len(crashed) > 0 {
+		exit(exitCodeOnFailure)
+	}
+	exit(exitCodeOnSuccess)
+}
+
+// workerOptions passes the relevant command line options to the workers
+func workerOptions() []string {
+	result := []string{}
+	for i := 0; i < len(cmdLineOptions); i++ {
+		option := lower(cmdLineOptions[i])
+		switch option {
+		case "-load", "-report", "-history", "-record":
+			i++ // skip the value as well
+		case "-silent", "-debug", "-curl":
+		default:
+			result = append(result, cmdLineOptions[i])
+		}
+	}
+	return result
+}
+
+func aggregateSamples(folder string, elapsed time.Duration) []*labelStats {
+	byLabel := map[string]*labelStats{}
+	files, _ := filepath.Glob(filepath.Join(folder, "*.json"))
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			reportError(err, "Opening samples [%s]", name)
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var sample loadSample
+			if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+				continue
+			}
+			stats, found := byLabel[sample.Label]
+			if !found {
+				stats = &labelStats{Label: sample.Label}
+				byLabel[sample.Label] = stats
+			}
+			if sample.Check {
+				// failed check, attributed to the request that preceded it (but not counted as its error)
+				stats.Failures++
+				continue
+			}
+			stats.Requests++
+			if len(sample.Error) > 0 || sample.Status >= 400 {
+				stats.Errors++
+			}
+			if len(sample.Error) == 0 {
+				stats.latencies = append(stats.latencies, sample.Latency)
+			}
+		}
+		_ = file.Close()
+	}
+
+	result := make([]*labelStats, 0, len(byLabel))
+	for _, stats := range byLabel {
+		sort.Float64s(stats.latencies)
+		stats.P50 = percentile(stats.latencies, 50)
+		stats.P90 = percentile(stats.latencies, 90)
+		stats.P99 = percentile(stats.latencies, 99)
+		if len(stats.latencies) > 0 {
+			stats.Max = stats.latencies[len(stats.latencies)-1]
+		}
+		if stats.Requests > 0 {
+			stats.ErrorRate = round(100 * float64(stats.Errors) / float64(stats.Requests))
+		}
+		stats.Throughput = round(float64(stats.Requests) / elapsed.Seconds())
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Label < result[j].Label })
+	return result
+}
+
+// percentile uses the nearest-rank method on the sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func printLoadTable(stats []*labelStats, elapsed time.Duration) {
+	const format = "%-40s %9v %7v %7v %7v %9v %9v %9v %9v %9v"
+	report(format, "request", "requests", "errors", "err%", "checks", "req/s", "p50 ms", "p90 ms", "p99 ms", "max ms")
+
+	total, errors, failures := 0, 0, 0
+	for _, one := range stats {
+		// the rows go the same way as the header and the totals (-silent included)
+		report(format, one.Label, one.Requests, one.Errors, one.ErrorRate, one.Failures, one.Throughput, one.P50, one.P90, one.P99, one.Max)
+		total += one.Requests
+		errors += one.Errors
+		failures += one.Failures
+	}
+	report("%v requests, %v errors, %v failed checks in %s (%.2f req/s)", total, errors, failures, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds())
+}
+
+// the worker side
+
+func enableLoadWorker(spec string) {
+	loadWorker = true
+	for _, option := range strings.Split(spec, ",") {
+		key, value := splitBy(option, "=")
+		switch key {
+		case "until":
+			nanos, err := strconv.ParseInt(value, 10, 64)
+			quitOnError(err, "Parsing worker deadline [%s]", value)
+			workerUntil = time.Unix(0, nanos)
+		case "samples":
+			file, err := os.Create(value)
+			quitOnError(err, "Creating samples file [%s]", value)
+			workerOutput = json.NewEncoder(file)
+			onExit(func() { _ = file.Close() })
+		case "section":
+			loadSection = value
+		}
+	}
+}
+
+func runLoadWorker(script string) {
+	statements := parseScript(script, currentFile)
+	if len(loadSection) > 0 {
+		preamble, selected := selectSection(statements, loadSection)
+		if len(selected) == 0 {
+			quit("cannot find SECTION [%s]", loadSection)
+		}
+		runStatements(preamble)
+		statements = selected
+	}
+
+	for time.Now().Before(workerUntil) {
+		runStatements(statements)
+	}
+	exit(finalExitCode())
+}
+
+func recordSample(label string, latency time.Duration, status int, err error) {
+	if workerOutput == nil || time.Now().After(workerUntil) {
+		// the checks of a request left out are left out as well
+		lastSampleLabel = ""
+		return
+	}
+	sample := loadSample{Label: label, Latency: float64(latency.Microseconds()) / 1000, Status: status}
+	if err != nil {
+		sample.Error = err.Error()
+	}
+	lastSampleLabel = label
+	_ = workerOutput.Encode(&sample)
+}
+
+func recordFailedCheck() {
+	if workerOutput != nil && len(lastSampleLabel) > 0 {
+		_ = workerOutput.Encode(&loadSample{Label: lastSampleLabel, Check: true})
+	}
+}
+
+func sampleLabel(settings requestOptions, verb string) string {
+	if len(settings.label) > 0 {
+		return settings.label
+	}
+	return requestTemplate(verb)
+}
+
+// requestTemplate names the request after the (unexpanded) command, e.g. "GET /orders/${order}"
+func requestTemplate(verb string) string {
+	cmd, rest := split(currentCommand)
+	if lower(cmd) == "request" {
+		_, rest = split(rest)
+	}
+	target, _ := split(rest)
+	return verb + " " + target
+}
@@ -0,0 +1,534 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"gopkg.in/yaml.v3"
+)
+
+// the specs (Swagger 2.0 or OpenAPI 3.x, in YAML or JSON) are loaded into spec.Swagger:
+// an OpenAPI 3 document is reshaped into the Swagger 2.0 one first
+
+// the extension of the responses holding the status ranges (2XX) of an OpenAPI 3 operation
+const statusRangesExtension = "x-status-ranges"
+
+type apiOperation struct {
+	method    string
+	path      string
+	operation *spec.Operation
+	params    []spec.Parameter
+}
+
+var (
+	// the order the operations of a path are listed in
+	apiMethods = []string{
+		http.MethodGet,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+		http.MethodHead,
+		http.MethodOptions,
+	}
+
+	serverVariablePattern = regexp.MustCompile(`\{([^{}]+)\}`)
+	statusRangePattern    = regexp.MustCompile(`^[1-5][xX][xX]$`)
+
+	componentRefs = strings.NewReplacer(
+		"#/components/schemas/", "#/definitions/",
+		"#/components/parameters/", "#/parameters/",
+		"#/components/responses/", "#/responses/",
+	)
+
+	// the media types a json schema is taken from, in the order of preference
+	jsonMediaTypes = []string{contentTypeJson, "application/problem+json", "*/*"}
+)
+
+func loadApiSpec(name string) *spec.Swagger {
+	data, err := ioutil.ReadFile(name)
+	quitOnError(err, "Opening file %s", name)
+
+	var document interface{}
+	quitOnError(yaml.Unmarshal(data, &document), "Parsing spec [%s]", name)
+	root, ok := plainMaps(document).(map[string]interface{})
+	if !ok {
+		quit("[%s] is not an OpenAPI/Swagger spec", name)
+	}
+	if version, found := root["openapi"]; found {
+		debug("converting OpenAPI %v spec", version)
+		root = convertOpenApi(root)
+	} else if _, found := root["swagger"]; !found {
+		quit("[%s] is neither an OpenAPI 3 nor a Swagger 2 spec", name)
+	}
+
+	converted, err := json.Marshal(root)
+	quitOnError(err, "Converting spec [%s]", name)
+	var swagger spec.Swagger
+	quitOnError(json.Unmarshal(converted, &swagger), "Loading spec [%s]", name)
+	return &swagger
+}
+
+// plainMaps turns the yaml maps with the non-string keys (e.g. 200:) into map[string]interface{}
+func plainMaps(src interface{}) interface{} {
+	switch value := src.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, one := range value {
+			result[fmt.Sprint(key)] = plainMaps(one)
+		}
+		return result
+	case map[string]interface{}:
+		for key, one := range value {
+			value[key] = plainMaps(one)
+		}
+		return value
+	case []interface{}:
+		for at, one := range value {
+			value[at] = plainMaps(one)
+		}
+		return value
+	}
+	return src
+}
+
+// convertOpenApi reshapes an OpenAPI 3 document into a Swagger 2.0 one (as far as gurl cares)
+func convertOpenApi(root map[string]interface{}) map[string]interface{} {
+	root = rewriteRefs(root).(map[string]interface{})
+	components := asMap(root["components"])
+	bodies := asMap(components["requestBodies"])
+
+	result := map[string]interface{}{
+		"swagger":     "2.0",
+		"info":        root["info"],
+		"tags":        root["tags"],
+		"security":    root["security"],
+		"definitions": components["schemas"],
+	}
+	if servers, ok := root["servers"].([]interface{}); ok && len(servers) > 0 {
+		server := asMap(servers[0])
+		address := fmt.Sprint(server["url"])
+		variables := asMap(server["variables"])
+		address = serverVariablePattern.ReplaceAllStringFunc(address, func(variable string) string {
+			return fmt.Sprint(asMap(variables[variable[1:len(variable)-1]])["default"])
+		})
+		if parsed, err := url.Parse(address); err == nil {
+			if len(parsed.Scheme) > 0 {
+				result["schemes"] = []string{parsed.Scheme}
+			}
+			result["host"] = parsed.Host
+			result["basePath"] = strings.TrimSuffix(parsed.Path, "/")
+		}
+	}
+
+	parameters := map[string]interface{}{}
+	for name, parameter := range asMap(components["parameters"]) {
+		parameters[name] = convertParameter(asMap(parameter))
+	}
+	result["parameters"] = parameters
+
+	responses := map[string]interface{}{}
+	for name, response := range asMap(components["responses"]) {
+		responses[name] = convertResponse(asMap(response))
+	}
+	result["responses"] = responses
+
+	securities := map[string]interface{}{}
+	for name, scheme := range asMap(components["securitySchemes"]) {
+		securities[name] = convertSecurityScheme(asMap(scheme))
+	}
+	result["securityDefinitions"] = securities
+
+	paths := map[string]interface{}{}
+	for path, raw := range asMap(root["paths"]) {
+		item := asMap(raw)
+		converted := map[string]interface{}{}
+		if list, ok := item["parameters"].([]interface{}); ok {
+			converted["parameters"] = convertParameters(list)
+		}
+		for _, method := range apiMethods {
+			operation := asMap(item[lower(method)])
+			if len(operation) == 0 {
+				continue
+			}
+			converted[lower(method)] = convertOperation(operation, bodies)
+		}
+		paths[path] = converted
+	}
+	result["paths"] = paths
+	return result
+}
+
+func convertOperation(src map[string]interface{}, bodies map[string]interface{}) map[string]interface{} {
+	operation := map[string]interface{}{}
+	for _, key := range []string{"operationId", "summary", "description", "tags", "deprecated", "security"} {
+		if value, found := src[key]; found {
+			operation[key] = value
+		}
+	}
+
+	list, _ := src["parameters"].([]interface{})
+	parameters := convertParameters(list)
+	if body := asMap(src["requestBody"]); len(body) > 0 {
+		if ref, found := body["$ref"].(string); found {
+			body = asMap(bodies[ref[strings.LastIndex(ref, "/")+1:]])
+		}
+		mediaType, media := pickMedia(asMap(body["content"]))
+		if len(mediaType) > 0 {
+			operation["consumes"] = []string{mediaType}
+		}
+		schema := asMap(media["schema"])
+		if example, found := media["example"]; found {
+			schema = withExample(schema, example)
+		}
+		if strings.HasPrefix(mediaType, contentTypeForm) || strings.HasPrefix(mediaType, "multipart/") {
+			// the properties become the form fields
+			required := map[string]bool{}
+			for _, name := range asList(schema["required"]) {
+				required[fmt.Sprint(name)] = true
+			}
+			for _, name := range sortedKeys(asMap(schema["properties"])) {
+				field := asMap(asMap(schema["properties"])[name])
+				parameter := flattenSchema(field)
+				if field["format"] == "binary" {
+					parameter["type"] = "file"
+				}
+				parameter["name"], parameter["in"], parameter["required"] = name, "formData", required[name]
+				parameters = append(parameters, parameter)
+			}
+		} else {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     "body",
+				"in":       "body",
+				"required": body["required"] == true,
+				"schema":   schema,
+			})
+		}
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	responses, ranges := map[string]interface{}{}, map[string]interface{}{}
+	for code, response := range asMap(src["responses"]) {
+		if statusRangePattern.MatchString(code) {
+			// swagger 2.0 has no 2XX (and the likes), they are kept aside
+			ranges[strings.ToUpper(code)] = convertResponse(asMap(response))
+			continue
+		}
+		responses[code] = convertResponse(asMap(response))
+	}
+	if len(ranges) > 0 {
+		responses[statusRangesExtension] = ranges
+	}
+	operation["responses"] = responses
+	return operation
+}
+
+func convertParameters(list []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(list))
+	for _, parameter := range list {
+		result = append(result, convertParameter(asMap(parameter)))
+	}
+	return result
+}
+
+// convertParameter moves the schema of a (non-body) parameter into the parameter itself
+func convertParameter(src map[string]interface{}) map[string]interface{} {
+	if _, found := src["$ref"]; found {
+		return src
+	}
+	result := flattenSchema(asMap(src["schema"]))
+	for key, value := range src {
+		if key != "schema" && key != "examples" && key != "style" && key != "explode" {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func convertResponse(src map[string]interface{}) map[string]interface{} {
+	if _, found := src["$ref"]; found {
+		return src
+	}
+	result := map[string]interface{}{"description": src["description"]}
+	if _, media := pickMedia(asMap(src["content"])); len(media) > 0 {
+		schema := asMap(media["schema"])
+		if example, found := media["example"]; found {
+			schema = withExample(schema, example)
+		}
+		if len(schema) > 0 {
+			result["schema"] = schema
+		}
+	}
+	headers := map[string]interface{}{}
+	for name, raw := range asMap(src["headers"]) {
+		header := asMap(raw)
+		converted := flattenSchema(asMap(header["schema"]))
+		if header["required"] == true {
+			// not a part of Swagger 2.0, yet worth keeping
+			converted["x-required"] = true
+		}
+		converted["description"] = header["description"]
+		headers[name] = converted
+	}
+	if len(headers) > 0 {
+		result["headers"] = headers
+	}
+	return result
+}
+
+func convertSecurityScheme(src map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{"description": src["description"]}
+	switch lower(fmt.Sprint(src["type"])) {
+	case "http":
+		if lower(fmt.Sprint(src["scheme"])) == "basic" {
+			result["type"] = "basic"
+		} else {
+			result["type"], result["in"], result["name"] = "apiKey", "header", headerAuthorization
+			result["x-scheme"] = src["scheme"]
+		}
+	case "apikey":
+		result["type"], result["in"], result["name"] = "apiKey", src["in"], src["name"]
+	default:
+		result["type"] = "oauth2"
+		for flow, raw := range asMap(src["flows"]) {
+			settings := asMap(raw)
+			result["flow"] = map[string]string{"clientCredentials": "application", "authorizationCode": "accessCode"}[flow]
+			if result["flow"] == "" {
+				result["flow"] = flow
+			}
+			result["tokenUrl"], result["authorizationUrl"] = settings["tokenUrl"], settings["authorizationUrl"]
+			break
+		}
+	}
+	return result
+}
+
+// flattenSchema returns the parts of the schema a Swagger 2.0 parameter/header holds
+func flattenSchema(schema map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, key := range []string{"type", "format", "items", "enum", "default", "example", "minimum", "maximum", "pattern"} {
+		if value, found := schema[key]; found {
+			result[key] = value
+		}
+	}
+	if _, found := result["type"]; !found {
+		result["type"] = "string"
+	}
+	return result
+}
+
+func withExample(schema map[string]interface{}, example interface{}) map[string]interface{} {
+	result := map[string]interface{}{"example": example}
+	for key, value := range schema {
+		if key == "$ref" {
+			// a $ref does not allow any siblings
+			result["allOf"] = []interface{}{map[string]interface{}{key: value}}
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// pickMedia returns the json media type (or the first one) of the content
+func pickMedia(content map[string]interface{}) (string, map[string]interface{}) {
+	for _, preferred := range jsonMediaTypes {
+		if media, found := content[preferred]; found {
+			return preferred, asMap(media)
+		}
+	}
+	for _, mediaType := range sortedKeys(content) {
+		if strings.HasSuffix(mediaType, "+json") {
+			return mediaType, asMap(content[mediaType])
+		}
+	}
+	for _, mediaType := range sortedKeys(content) {
+		return mediaType, asMap(content[mediaType])
+	}
+	return "", nil
+}
+
+func rewriteRefs(src interface{}) interface{} {
+	switch value := src.(type) {
+	case map[string]interface{}:
+		for key, one := range value {
+			if ref, ok := one.(string); ok && key == "$ref" {
+				value[key] = componentRefs.Replace(ref)
+				continue
+			}
+			value[key] = rewriteRefs(one)
+		}
+	case []interface{}:
+		for at, one := range value {
+			value[at] = rewriteRefs(one)
+		}
+	}
+	return src
+}
+
+func asMap(src interface{}) map[string]interface{} {
+	if value, ok := src.(map[string]interface{}); ok {
+		return value
+	}
+	return map[string]interface{}{}
+}
+
+func asList(src interface{}) []interface{} {
+	if value, ok := src.([]interface{}); ok {
+		return value
+	}
+	return nil
+}
+
+func sortedKeys(src map[string]interface{}) []string {
+	keys := make([]string, 0, len(src))
+	for key := range src {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// the helpers over the loaded spec
+
+// apiOperations lists the operations sorted by path (and by method within a path)
+func apiOperations(swagger *spec.Swagger) []apiOperation {
+	if swagger.Paths == nil {
+		return nil
+	}
+	paths := make([]string, 0, len(swagger.Paths.Paths))
+	for path := range swagger.Paths.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	operations := []apiOperation{}
+	for _, path := range paths {
+		item := swagger.Paths.Paths[path]
+		byMethod := map[string]*spec.Operation{
+			http.MethodGet:     item.Get,
+			http.MethodPost:    item.Post,
+			http.MethodPut:     item.Put,
+			http.MethodPatch:   item.Patch,
+			http.MethodDelete:  item.Delete,
+			http.MethodHead:    item.Head,
+			http.MethodOptions: item.Options,
+		}
+		for _, method := range apiMethods {
+			operation := byMethod[method]
+			if operation == nil {
+				continue
+			}
+			operations = append(operations, apiOperation{
+				method:    method,
+				path:      path,
+				operation: operation,
+				params:    operationParams(swagger, item.Parameters, operation.Parameters),
+			})
+		}
+	}
+	return operations
+}
+
+// operationParams resolves the parameters, the operation's ones override the path's ones
+func operationParams(swagger *spec.Swagger, common, own []spec.Parameter) []spec.Parameter {
+	result := []spec.Parameter{}
+	position := map[string]int{}
+	for _, parameter := range append(append([]spec.Parameter{}, common...), own...) {
+		parameter = resolveParameter(swagger, parameter)
+		key := parameter.In + ":" + parameter.Name
+		if at, found := position[key]; found {
+			result[at] = parameter
+			continue
+		}
+		position[key] = len(result)
+		result = append(result, parameter)
+	}
+	return result
+}
+
+func resolveParameter(swagger *spec.Swagger, parameter spec.Parameter) spec.Parameter {
+	for depth := 0; depth < maxRefDepth && len(parameter.Ref.String()) > 0; depth++ {
+		found, ok := swagger.Parameters[refName(parameter.Ref, "#/parameters/")]
+		if !ok {
+			quit("cannot resolve parameter [%s]", parameter.Ref.String())
+		}
+		parameter = found
+	}
+	return parameter
+}
+
+func resolveResponse(swagger *spec.Swagger, response spec.Response) spec.Response {
+	for depth := 0; depth < maxRefDepth && len(response.Ref.String()) > 0; depth++ {
+		found, ok := swagger.Responses[refName(response.Ref, "#/responses/")]
+		if !ok {
+			quit("cannot resolve response [%s]", response.Ref.String())
+		}
+		response = found
+	}
+	return response
+}
+
+// resolveSchema follows the $refs of the schema (returns nil when it cannot)
+func resolveSchema(swagger *spec.Swagger, schema *spec.Schema) *spec.Schema {
+	for depth := 0; schema != nil && len(schema.Ref.String()) > 0; depth++ {
+		if depth == maxRefDepth {
+			return nil
+		}
+		found, ok := swagger.Definitions[refName(schema.Ref, "#/definitions/")]
+		if !ok {
+			debug("cannot resolve schema [%s]", schema.Ref.String())
+			return nil
+		}
+		schema = &found
+	}
+	return schema
+}
+
+func refName(ref spec.Ref, prefix string) string {
+	name := strings.TrimPrefix(ref.String(), prefix)
+	// ~1 and ~0 are the escaped / and ~ of json pointers
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(name)
+}
+
+// successStatus returns the lowest documented 2xx (or 3xx) status, 0 when there is none
+func successStatus(operation *spec.Operation) int {
+	if operation.Responses == nil {
+		return 0
+	}
+	lowest := 0
+	for code := range operation.Responses.StatusCodeResponses {
+		if code >= 200 && code < 400 && (lowest == 0 || code < lowest) {
+			lowest = code
+		}
+	}
+	return lowest
+}
+
+func schemaType(schema *spec.Schema) string {
+	for _, one := range schema.Type {
+		if one != "null" {
+			return one
+		}
+	}
+	switch {
+	case len(schema.Properties) > 0 || schema.AdditionalProperties != nil:
+		return "object"
+	case schema.Items != nil:
+		return "array"
+	}
+	return ""
+}
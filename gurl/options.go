@@ -0,0 +1,75 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GET:login /auth
+// GET:nobody:save=files/report-${now:unix}.pdf /reports/${id}
+//   the options follow the verb and are separated by ":" (the ones inside ${...} do not count),
+//   save= takes the rest of the options (so it goes last, and C:\reports\x.pdf works too)
+
+type requestOptions struct {
+	label  string
+	save   string
+	noBody bool
+}
+
+var labelPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+func parseRequestOptions(options string) requestOptions {
+	result := requestOptions{}
+	if len(options) == 0 {
+		return result
+	}
+
+	for _, option := range splitOptions(options) {
+		option = strings.TrimSpace(option)
+		switch {
+		case len(option) == 0:
+			continue
+		case lower(option) == "nobody":
+			result.noBody = true
+		case strings.HasPrefix(lower(option), "save="):
+			result.save = expand(option[len("save="):])
+			if len(result.save) == 0 {
+				quit("request option [%s] requires a file name", option)
+			}
+		case labelPattern.MatchString(option):
+			if len(result.label) > 0 {
+				quit("request can have only one label, got [%s] and [%s]", result.label, option)
+			}
+			result.label = option
+		default:
+			quit("unknown request option [%s]", option)
+		}
+	}
+	return result
+}
+
+// splitOptions splits the options on the colons outside of ${...}; save= gets the rest of them
+func splitOptions(options string) []string {
+	result := []string{}
+	depth, start := 0, 0
+	for at := 0; at < len(options); at++ {
+		switch {
+		case strings.HasPrefix(options[at:], "${"):
+			depth++
+			at++
+		case options[at] == '}' && depth > 0:
+			depth--
+		case options[at] == ':' && depth == 0:
+			if strings.HasPrefix(lower(strings.TrimSpace(options[start:at])), "save=") {
+				return append(result, options[start:])
+			}
+			result = append(result, options[start:at])
+			start = at + 1
+		}
+	}
+	return append(result, options[start:])
+}
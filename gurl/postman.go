@@ -0,0 +1,462 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Postman collection (format v2.1)
+
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+type (
+	postmanCollection struct {
+		Info     postmanInfo       `json:"info"`
+		Item     []postmanItem     `json:"item"`
+		Auth     *postmanAuth      `json:"auth,omitempty"`
+		Event    []postmanEvent    `json:"event,omitempty"`
+		Variable []postmanKeyValue `json:"variable,omitempty"`
+	}
+
+	postmanInfo struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	}
+
+	postmanItem struct {
+		Name    string          `json:"name"`
+		Item    []postmanItem   `json:"item,omitempty"`
+		Request *postmanRequest `json:"request,omitempty"`
+		Auth    *postmanAuth    `json:"auth,omitempty"`
+		Event   []postmanEvent  `json:"event,omitempty"`
+	}
+
+	postmanRequest struct {
+		Method string            `json:"method"`
+		Header []postmanKeyValue `json:"header"`
+		Url    postmanUrl        `json:"url"`
+		Body   *postmanBody      `json:"body,omitempty"`
+		Auth   *postmanAuth      `json:"auth,omitempty"`
+	}
+
+	postmanAuth struct {
+		Type   string            `json:"type"`
+		Basic  []postmanKeyValue `json:"basic,omitempty"`
+		Bearer []postmanKeyValue `json:"bearer,omitempty"`
+	}
+
+	postmanUrl struct {
+		Raw string `json:"raw"`
+	}
+
+	postmanBody struct {
+		Mode       string            `json:"mode"`
+		Raw        string            `json:"raw,omitempty"`
+		UrlEncoded []postmanKeyValue `json:"urlencoded,omitempty"`
+		FormData   []postmanKeyValue `json:"formdata,omitempty"`
+		File       *postmanKeyValue  `json:"file,omitempty"`
+	}
+
+	postmanKeyValue struct {
+		Key         string      `json:"key,omitempty"`
+		Value       interface{} `json:"value,omitempty"`
+		Type        string      `json:"type,omitempty"`
+		Src         interface{} `json:"src,omitempty"`
+		ContentType string      `json:"contentType,omitempty"`
+		Disabled    bool        `json:"disabled,omitempty"`
+		Enabled     *bool       `json:"enabled,omitempty"`
+	}
+
+	postmanEvent struct {
+		Listen string        `json:"listen"`
+		Script postmanScript `json:"script"`
+	}
+
+	postmanScript struct {
+		Type string   `json:"type"`
+		Exec []string `json:"exec"`
+	}
+)
+
+// UnmarshalJSON accepts the url as either a string or an object
+func (u *postmanUrl) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &u.Raw); err == nil {
+		return nil
+	}
+	var full struct {
+		Raw string `json:"raw"`
+	}
+	err := json.Unmarshal(data, &full)
+	u.Raw = full.Raw
+	return err
+}
+
+func exportPostman(requests []*exportedRequest) []byte {
+	name := strings.TrimSuffix(filepath.Base(filename()), filepath.Ext(filename()))
+	collection := postmanCollection{
+		Info: postmanInfo{Name: name, Schema: postmanSchema},
+		Item: []postmanItem{},
+	}
+
+	variables := map[string]bool{}
+	for _, current := range requests {
+		item := postmanItem{Name: current.name, Request: &postmanRequest{
+			Method: current.method,
+			Header: []postmanKeyValue{},
+			Url:    postmanUrl{Raw: current.url},
+		}}
+		for _, header := range current.headers {
+			item.Request.Header = append(item.Request.Header, postmanKeyValue{Key: header[0], Value: header[1]})
+		}
+
+		switch {
+		case current.form != nil:
+			item.Request.Body = postmanFormBody(current.form)
+		case len(current.body) > 0:
+			item.Request.Body = &postmanBody{Mode: "raw", Raw: current.body}
+		}
+
+		script := []string{}
+		for _, capture := range current.captures {
+			if !capture.exportable() {
+				script = append(script, fmt.Sprintf("// %s: cannot capture ${%s:%s} here", capture.variable, capture.namespace, capture.param))
+				continue
+			}
+			script = append(script, fmt.Sprintf("pm.collectionVariables.set(%q, %s);", capture.variable, postmanValue(capture.namespace, capture.param)))
+			variables[capture.variable] = true
+		}
+		for _, check := range current.checks {
+			script = append(script, postmanTest(check)...)
+		}
+		if len(script) > 0 {
+			item.Event = []postmanEvent{{Listen: "test", Script: postmanScript{Type: "text/javascript", Exec: script}}}
+		}
+		collection.Item = append(collection.Item, item)
+	}
+
+	names := make([]string, 0, len(variables))
+	for variable := range variables {
+		names = append(names, variable)
+	}
+	sort.Strings(names)
+	for _, variable := range names {
+		collection.Variable = append(collection.Variable, postmanKeyValue{Key: variable})
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent(marshalPrefix, marshalIndent)
+	quitOnError(encoder.Encode(&collection), "Marshalling Postman collection")
+	return out.Bytes()
+}
+
+func postmanFormBody(form *formBody) *postmanBody {
+	fields := []postmanKeyValue{}
+	for _, field := range form.fields {
+		switch {
+		case field.file && form.multipart:
+			fields = append(fields, postmanKeyValue{Key: field.name, Type: "file", Src: field.value, ContentType: field.contentType})
+		case form.multipart:
+			fields = append(fields, postmanKeyValue{Key: field.name, Value: field.value, Type: "text"})
+		default:
+			fields = append(fields, postmanKeyValue{Key: field.name, Value: field.value})
+		}
+	}
+	if form.multipart {
+		return &postmanBody{Mode: "formdata", FormData: fields}
+	}
+	return &postmanBody{Mode: "urlencoded", UrlEncoded: fields}
+}
+
+// postmanValue is the javascript expression of the response value
+func postmanValue(namespace, param string) string {
+	switch namespace {
+	case "response":
+		expression := "pm.response.json()"
+		for _, step := range jsonPath(param) {
+			if isIndex(step) {
+				expression += "[" + step + "]"
+			} else {
+				expression += "[" + strconv.Quote(step) + "]"
+			}
+		}
+		return expression
+	case "response.raw":
+		return "pm.response.text()"
+	case "status":
+		return "pm.response.code"
+	case "status.text":
+		return "pm.response.status"
+	case "header":
+		return fmt.Sprintf("pm.response.headers.get(%q)", param)
+	case "cookie":
+		return fmt.Sprintf("pm.cookies.get(%q)", param)
+	}
+	return ""
+}
+
+// postmanTest translates the REQUIRE condition into a Postman test
+func postmanTest(condition string) []string {
+	untranslated := []string{fmt.Sprintf("// REQUIRE %s (cannot be translated)", condition)}
+
+	left, rest := split(condition)
+	operator, right := split(rest)
+	operator = lower(operator)
+
+	match := singleReference.FindStringSubmatch(left)
+	if match == nil {
+		return untranslated
+	}
+	label, namespace, param, found := exportReference(match[1])
+	if !found || len(label) > 0 {
+		return untranslated
+	}
+	actual := postmanValue(namespace, param)
+	if len(actual) == 0 {
+		return untranslated
+	}
+	expected := fmt.Sprintf("pm.variables.replaceIn(%q)", right)
+
+	_, isCheck := conditionChecks[operator]
+	assertion := ""
+	switch {
+	case len(right) > 0 && operator != "length":
+		assertion = postmanCompare(operator, actual, expected)
+	case isCheck && len(right) == 0:
+		assertion = postmanCheck(operator, actual)
+	case operator == "length":
+		compare, size := split(right)
+		assertion = postmanCompare(compare, actual+".length", fmt.Sprintf("pm.variables.replaceIn(%q)", size))
+	case len(rest) > 0 && len(right) == 0:
+		// the legacy form: ${response:status} HEALTHY
+		assertion = postmanCompare("==", actual, fmt.Sprintf("pm.variables.replaceIn(%q)", rest))
+	}
+	if len(assertion) == 0 {
+		return untranslated
+	}
+
+	return []string{
+		fmt.Sprintf("pm.test(%q, function () {", "REQUIRE "+condition),
+		"    " + assertion,
+		"});",
+	}
+}
+
+func postmanCompare(operator, actual, expected string) string {
+	switch lower(operator) {
+	case "==":
+		return fmt.Sprintf("pm.expect(String(%s)).to.eql(%s);", actual, expected)
+	case "!=":
+		return fmt.Sprintf("pm.expect(String(%s)).to.not.eql(%s);", actual, expected)
+	case "<":
+		return fmt.Sprintf("pm.expect(Number(%s)).to.be.below(Number(%s));", actual, expected)
+	case "<=":
+		return fmt.Sprintf("pm.expect(Number(%s)).to.be.at.most(Number(%s));", actual, expected)
+	case ">":
+		return fmt.Sprintf("pm.expect(Number(%s)).to.be.above(Number(%s));", actual, expected)
+	case ">=":
+		return fmt.Sprintf("pm.expect(Number(%s)).to.be.at.least(Number(%s));", actual, expected)
+	case "=~", "matches":
+		return fmt.Sprintf("pm.expect(String(%s)).to.match(new RegExp(%s));", actual, expected)
+	case "!~":
+		return fmt.Sprintf("pm.expect(String(%s)).to.not.match(new RegExp(%s));", actual, expected)
+	case "contains":
+		return fmt.Sprintf("pm.expect(String(%s)).to.include(%s);", actual, expected)
+	case "!contains":
+		return fmt.Sprintf("pm.expect(String(%s)).to.not.include(%s);", actual, expected)
+	case "prefix", "=(":
+		return fmt.Sprintf("pm.expect(String(%s).startsWith(%s)).to.be.true;", actual, expected)
+	case "suffix", "=)":
+		return fmt.Sprintf("pm.expect(String(%s).endsWith(%s)).to.be.true;", actual, expected)
+	}
+	return ""
+}
+
+func postmanCheck(check, actual string) string {
+	switch check {
+	case "exists":
+		return fmt.Sprintf("pm.expect(%s).to.not.be.undefined;", actual)
+	case "absent":
+		return fmt.Sprintf("pm.expect(%s).to.be.undefined;", actual)
+	case "empty":
+		return fmt.Sprintf("pm.expect(%s).to.be.empty;", actual)
+	case "not-empty":
+		return fmt.Sprintf("pm.expect(%s).to.not.be.empty;", actual)
+	case "is-number":
+		return fmt.Sprintf("pm.expect(%s).to.be.a(\"number\");", actual)
+	case "is-string":
+		return fmt.Sprintf("pm.expect(%s).to.be.a(\"string\");", actual)
+	case "is-bool", "is-boolean":
+		return fmt.Sprintf("pm.expect(%s).to.be.a(\"boolean\");", actual)
+	case "is-array":
+		return fmt.Sprintf("pm.expect(%s).to.be.an(\"array\");", actual)
+	case "is-object":
+		return fmt.Sprintf("pm.expect(%s).to.be.an(\"object\");", actual)
+	case "is-null":
+		return fmt.Sprintf("pm.expect(%s).to.be.null;", actual)
+	}
+	return ""
+}
+
+// the other way: the collection into a script
+
+func importPostman(data []byte) *importedScript {
+	var collection postmanCollection
+	quitOnError(json.Unmarshal(data, &collection), "Parsing Postman collection [%s]", filename())
+
+	script := &importedScript{source: fmt.Sprintf("Postman collection %q", collection.Info.Name)}
+	for _, variable := range collection.Variable {
+		if !variable.Disabled {
+			script.variables = append(script.variables, [2]string{variable.Key, postmanVariables(postmanText(variable.Value))})
+		}
+	}
+
+	notes := postmanScriptNotes(collection.Event, "the collection")
+	importPostmanItems(script, collection.Item, "", collection.Auth, notes)
+	return script
+}
+
+func importPostmanItems(script *importedScript, items []postmanItem, section string, auth *postmanAuth, notes []string) {
+	for _, item := range items {
+		if item.Request == nil {
+			// a folder
+			folder := item.Name
+			if len(section) > 0 {
+				folder = section + "/" + item.Name
+			}
+			inherited := auth
+			if item.Auth != nil {
+				inherited = item.Auth
+			}
+			importPostmanItems(script, item.Item, folder, inherited, append(notes, postmanScriptNotes(item.Event, "the folder "+item.Name)...))
+			continue
+		}
+
+		request := &importedRequest{
+			section: section,
+			name:    item.Name,
+			method:  strings.ToUpper(item.Request.Method),
+			url:     postmanVariables(item.Request.Url.Raw),
+			notes:   append(append([]string{}, notes...), postmanScriptNotes(item.Event, "the request")...),
+		}
+		if len(request.method) == 0 {
+			request.method = http.MethodGet
+		}
+		for _, header := range item.Request.Header {
+			if !header.Disabled {
+				request.headers = append(request.headers, [2]string{header.Key, postmanVariables(postmanText(header.Value))})
+			}
+		}
+
+		effective := auth
+		if item.Request.Auth != nil {
+			effective = item.Request.Auth
+		}
+		request.auth = postmanAuthCommand(effective, request)
+
+		if body := item.Request.Body; body != nil {
+			switch body.Mode {
+			case "raw":
+				request.body = postmanVariables(body.Raw)
+			case "urlencoded", "formdata":
+				form := &formBody{multipart: body.Mode == "formdata"}
+				fields := body.UrlEncoded
+				if form.multipart {
+					fields = body.FormData
+				}
+				for _, field := range fields {
+					if field.Disabled {
+						continue
+					}
+					one := formField{name: field.Key, value: postmanVariables(postmanText(field.Value))}
+					if field.Type == "file" {
+						one.file, one.value, one.contentType = true, postmanText(field.Src), field.ContentType
+					}
+					form.fields = append(form.fields, one)
+				}
+				request.form = form
+				request.headers = withoutHeader(request.headers, headerContentType)
+			case "file":
+				if body.File != nil {
+					request.body = externalFilePrefix + postmanText(body.File.Src)
+				}
+			case "":
+			default:
+				request.note("the body of [%s] mode", body.Mode)
+			}
+		}
+		script.requests = append(script.requests, request)
+	}
+}
+
+func postmanAuthCommand(auth *postmanAuth, request *importedRequest) string {
+	if auth == nil {
+		return ""
+	}
+	value := func(values []postmanKeyValue, key string) string {
+		for _, one := range values {
+			if one.Key == key {
+				return postmanVariables(postmanText(one.Value))
+			}
+		}
+		return ""
+	}
+	switch auth.Type {
+	case "basic":
+		return fmt.Sprintf(`AUTH basic "%s" "%s"`, value(auth.Basic, "username"), value(auth.Basic, "password"))
+	case "bearer":
+		return fmt.Sprintf(`AUTH bearer "%s"`, value(auth.Bearer, "token"))
+	case "noauth", "":
+		return ""
+	}
+	request.note("the [%s] authentication", auth.Type)
+	return ""
+}
+
+func postmanScriptNotes(events []postmanEvent, owner string) []string {
+	notes := []string{}
+	for _, event := range events {
+		if len(strings.TrimSpace(strings.Join(event.Script.Exec, ""))) > 0 {
+			notes = append(notes, fmt.Sprintf("the %s script of %s (%v line(s))", event.Listen, owner, len(event.Script.Exec)))
+		}
+	}
+	return notes
+}
+
+func postmanText(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return valueToText(value)
+}
+
+// importPostmanEnvironment reads the variables of a Postman environment
+func importPostmanEnvironment(name string) [][2]string {
+	data, err := ioutil.ReadFile(name)
+	quitOnError(err, "Opening file %s", name)
+
+	var environment struct {
+		Values []postmanKeyValue `json:"values"`
+	}
+	quitOnError(json.Unmarshal(data, &environment), "Parsing Postman environment [%s]", name)
+
+	variables := [][2]string{}
+	for _, one := range environment.Values {
+		if one.Enabled == nil || *one.Enabled {
+			variables = append(variables, [2]string{one.Key, postmanVariables(postmanText(one.Value))})
+		}
+	}
+	return variables
+}
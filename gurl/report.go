@@ -0,0 +1,294 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// -report junit=out.xml
+// -report tap
+
+type (
+	checkFailure struct {
+		message string
+		file    string
+		line    int
+		fatal   bool
+	}
+
+	sectionResult struct {
+		name     string
+		checks   int
+		failures []checkFailure
+		started  time.Time
+		duration time.Duration
+	}
+)
+
+var (
+	reportFormat = ""
+	reportFile   = ""
+
+	sectionResults = []*sectionResult{}
+	currentSection *sectionResult
+)
+
+func enableReport(spec string) {
+	format, file := splitBy(spec, "=")
+	switch lower(format) {
+	case reportFormatJUnit, reportFormatTap:
+		reportFormat = lower(format)
+		reportFile = file
+	default:
+		quit("unknown report format [%s]", format)
+	}
+	onExit(finishReport)
+}
+
+func continueOnFailure() bool {
+	// failed checks should not stop the load test workers either
+	return len(reportFormat) > 0 || loadWorker
+}
+
+func startSection(name string) {
+	closeSection()
+	currentSection = &sectionResult{name: name, started: time.Now()}
+	sectionResults = append(sectionResults, currentSection)
+}
+
+func closeSection() {
+	if currentSection != nil && currentSection.duration == 0 {
+		currentSection.duration = time.Since(currentSection.started)
+	}
+}
+
+func activeSection() *sectionResult {
+	if currentSection == nil {
+		name := "main"
+		if len(filename()) > 0 {
+			name = filepath.Base(filename())
+		}
+		startSection(name)
+	}
+	return currentSection
+}
+
+// checkPassed records a successful check against the current section
+func checkPassed() {
+	activeSection().checks++
+}
+
+// checkFailed records a failed check. Unless the report mode is on
+// the execution of the script stops right here
+func checkFailed(format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	if !continueOnFailure() {
+		quit("%s", message)
+	}
+
+	section := activeSection()
+	section.checks++
+	section.failures = append(section.failures, checkFailure{
+		message: message,
+		file:    currentFile,
+		line:    currentLineNumber,
+	})
+	responseFailure("FAILED: %s (script: [%s], line: %v)", message, currentFile, currentLineNumber)
+	if loadWorker {
+		recordFailedCheck()
+	}
+}
+
+// recordError makes a note of the error that terminates the execution
+func recordError(message string) {
+	if !continueOnFailure() {
+		return
+	}
+	section := activeSection()
+	section.checks++
+	section.failures = append(section.failures, checkFailure{
+		message: message,
+		file:    currentFile,
+		line:    currentLineNumber,
+		fatal:   true,
+	})
+}
+
+func failedChecks() int {
+	failed := 0
+	for _, section := range sectionResults {
+		failed += len(section.failures)
+	}
+	return failed
+}
+
+func finalExitCode() int {
+	if failedChecks() > 0 {
+		return exitCodeOnFailure
+	}
+	return exitCodeOnSuccess
+}
+
+func finishReport() {
+	closeSection()
+
+	total, failed := 0, 0
+	for _, section := range sectionResults {
+		total += section.checks
+		failed += len(section.failures)
+		status := "PASS"
+		if len(section.failures) > 0 {
+			status = "FAIL"
+		}
+		report("%s: %s (%v checks, %v failed, %s)", status, section.name, section.checks, len(section.failures), section.duration)
+	}
+
+	summary := responseSuccess
+	if failed > 0 {
+		summary = responseFailure
+	}
+	summary("Summary: %v checks in %v sections, %v passed, %v failed", total, len(sectionResults), total-failed, failed)
+
+	var out io.Writer = os.Stdout
+	if len(reportFile) > 0 {
+		file, err := os.Create(reportFile)
+		if err != nil {
+			reportError(err, "Creating report file [%s]", reportFile)
+			return
+		}
+		defer file.Close()
+		out = file
+	}
+
+	var err error
+	switch reportFormat {
+	case reportFormatJUnit:
+		err = writeJUnit(out)
+	case reportFormatTap:
+		err = writeTap(out)
+	}
+	if err != nil {
+		reportError(err, "Writing %s report", reportFormat)
+	} else if len(reportFile) > 0 {
+		report("%s report saved to %s", reportFormat, reportFile)
+	}
+}
+
+type (
+	junitSuites struct {
+		XMLName  xml.Name     `xml:"testsuites"`
+		Tests    int          `xml:"tests,attr"`
+		Failures int          `xml:"failures,attr"`
+		Errors   int          `xml:"errors,attr"`
+		Time     string       `xml:"time,attr"`
+		Suites   []junitSuite `xml:"testsuite"`
+	}
+
+	junitSuite struct {
+		Name      string      `xml:"name,attr"`
+		Tests     int         `xml:"tests,attr"`
+		Failures  int         `xml:"failures,attr"`
+		Errors    int         `xml:"errors,attr"`
+		Time      string      `xml:"time,attr"`
+		Timestamp string      `xml:"timestamp,attr,omitempty"`
+		Cases     []junitCase `xml:"testcase"`
+	}
+
+	junitCase struct {
+		Name       string         `xml:"name,attr"`
+		ClassName  string         `xml:"classname,attr"`
+		Assertions int            `xml:"assertions,attr"`
+		Time       string         `xml:"time,attr"`
+		Failures   []junitFailure `xml:"failure,omitempty"`
+		Errors     []junitFailure `xml:"error,omitempty"`
+	}
+
+	junitFailure struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func writeJUnit(out io.Writer) error {
+	suite := junitSuite{Name: filepath.Base(filename())}
+	var elapsed time.Duration
+	for _, section := range sectionResults {
+		if suite.Timestamp == "" {
+			suite.Timestamp = section.started.Format(time.RFC3339)
+		}
+		elapsed += section.duration
+
+		testcase := junitCase{
+			Name:       section.name,
+			ClassName:  suite.Name,
+			Assertions: section.checks,
+			Time:       seconds(section.duration),
+		}
+		for _, failure := range section.failures {
+			entry := junitFailure{
+				Message: failure.message,
+				Text:    fmt.Sprintf("%s:%v: %s", failure.file, failure.line, failure.message),
+			}
+			if failure.fatal {
+				testcase.Errors = append(testcase.Errors, entry)
+				suite.Errors++
+			} else {
+				testcase.Failures = append(testcase.Failures, entry)
+				suite.Failures++
+			}
+		}
+		suite.Cases = append(suite.Cases, testcase)
+	}
+	suite.Tests = len(suite.Cases)
+	suite.Time = seconds(elapsed)
+
+	suites := junitSuites{
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Time:     suite.Time,
+		Suites:   []junitSuite{suite},
+	}
+
+	data, err := xml.MarshalIndent(&suites, marshalPrefix, marshalIndent)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s%s\n", xml.Header, data)
+	return err
+}
+
+func writeTap(out io.Writer) error {
+	lines := []string{"TAP version 13", fmt.Sprintf("1..%v", len(sectionResults))}
+	for index, section := range sectionResults {
+		status := "ok"
+		if len(section.failures) > 0 {
+			status = "not ok"
+		}
+		lines = append(lines, fmt.Sprintf("%s %v - %s", status, index+1, section.name))
+		if len(section.failures) > 0 {
+			lines = append(lines, "  ---")
+			lines = append(lines, "  failures:")
+			for _, failure := range section.failures {
+				lines = append(lines, fmt.Sprintf("    - message: %q", failure.message))
+				lines = append(lines, fmt.Sprintf("      at: \"%s:%v\"", failure.file, failure.line))
+			}
+			lines = append(lines, "  ...")
+		}
+	}
+	_, err := fmt.Fprintln(out, strings.Join(lines, lineSeparator))
+	return err
+}
@@ -5,15 +5,38 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"math/rand"
 	"sort"
+	"strconv"
 	"strings"
 )
 
 func resolveAny(src interface{}, key string) (bool, string) {
+	found, value := lookupAny(src, key)
+	if !found {
+		return notFound()
+	}
+	// the numbers, booleans, null, objects and arrays come out as json
+	return true, valueToText(value)
+}
+
+// lookupAny evaluates the key (a path, a JSONPath or a JMESPath) and returns the value it points to as is
+func lookupAny(src interface{}, key string) (bool, interface{}) {
+	if isPathExpression(key) {
+		return lookupExpression(src, key)
+	}
+	return walkPath(src, key)
+}
 
+// walkPath walks the path (key) and returns the value it points to as is
+func walkPath(src interface{}, key string) (bool, interface{}) {
+	if len(key) == 0 {
+		return true, src
+	}
 	if src == nil {
-		return notFound() // todo: could it be a legit return value
+		return false, nil
 	}
 	switch actual := src.(type) {
 	case msi:
@@ -21,15 +44,32 @@ func resolveAny(src interface{}, key string) (bool, string) {
 	case slice:
 		return resolveSlice(actual, key)
 	case string:
-		if len(key) == 0 {
-			return true, actual
-		} else {
-			quit("stil have non empty path [%s] for terminal value [%s]", key, actual)
-		}
+		quit("stil have non empty path [%s] for terminal value [%s]", key, actual)
+	default:
+		debug("still have non empty path [%s] for terminal value [%v]", key, actual)
+	}
+	return false, nil
+}
+
+// valueToText renders any (json-originated) value as a text
+func valueToText(value interface{}) string {
+	switch actual := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return actual
+	case float64:
+		return strconv.FormatFloat(actual, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(actual)
+	case bool:
+		return strconv.FormatBool(actual)
 	default:
-		quit("unhandled type: %v", actual)
+		if data, err := json.Marshal(actual); err == nil {
+			return string(data)
+		}
+		return fmt.Sprintf("%v", actual)
 	}
-	return notFound()
 }
 
 func breakPath(src string) (string, string) {
@@ -52,22 +92,22 @@ func breakParam(src string) (string, string) {
 	return "", ""
 }
 
-func resolveMap(src msi, key string) (bool, string) {
+func resolveMap(src msi, key string) (bool, interface{}) {
 	first, remainder := breakPath(key)
 	if data, found := src[first]; found {
 		/*
 			if txt, okay := data.(string); okay {
 				return true, txt
 			}*/
-		return resolveAny(data, remainder)
+		return walkPath(data, remainder)
 	}
 
-	return notFound()
+	return false, nil
 }
 
-func resolveSlice(src slice, key string) (bool, string) {
+func resolveSlice(src slice, key string) (bool, interface{}) {
 	if len(src) == 0 {
-		return notFound()
+		return false, nil
 	}
 
 	cmds := strings.Split(key, ";")
@@ -97,10 +137,20 @@ func resolveSlice(src slice, key string) (bool, string) {
 			quit("cannot resolve the condition [%s]", key)
 		}
 
-		return notFound()
+		return false, nil
 	}
 
 	first, remainder := breakPath(key)
+	if position, err := strconv.Atoi(first); err == nil {
+		// items/0/id, items/-1/id (from the end)
+		if position < 0 {
+			position += len(src)
+		}
+		if position < 0 || position >= len(src) {
+			return false, nil
+		}
+		return walkPath(src[position], remainder)
+	}
 	name, options := breakParam(first)
 
 	index := indexInvalid
@@ -139,14 +189,14 @@ func resolveSlice(src slice, key string) (bool, string) {
 	}
 
 	if index != indexInvalid {
-		return resolveAny(src[index], remainder)
+		return walkPath(src[index], remainder)
 	} else {
 
 	}
 
 	comment(true, "********************* %s; %s; %s;", name, options, remainder)
 
-	return notFound()
+	return false, nil
 }
 
 func reduceSlice(src slice, key string) slice {
@@ -0,0 +1,46 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// scopes keep the values bound by FOREACH (and the likes) for the duration of the block.
+// ${name} gives the value itself, ${name:path} - the value inside of it.
+
+var (
+	scopes = []msi{}
+)
+
+func pushScope(values msi) {
+	scopes = append(scopes, values)
+}
+
+func popScope() {
+	if len(scopes) > 0 {
+		scopes = scopes[:len(scopes)-1]
+	}
+}
+
+func boundValue(key string) (bool, interface{}) {
+	if len(scopes) == 0 {
+		return false, nil
+	}
+
+	name, path := splitBy(key, ":")
+	for index := len(scopes) - 1; index >= 0; index-- {
+		if value, found := scopes[index][name]; found {
+			return lookupAny(value, path)
+		}
+	}
+	return false, nil
+}
+
+func isBound(key string) bool {
+	name, _ := splitBy(key, ":")
+	for _, scope := range scopes {
+		if _, found := scope[name]; found {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// statement is a single (possibly multi-line) command of a script
+type statement struct {
+	text  string
+	lines []string
+	file  string
+	line  int
+}
+
+type blockHandler func(opener statement, body []statement, closer statement)
+
+// the blocks: the opening command, followed by the body, followed by the closing command
+var (
+	blockHandlers map[string]blockHandler
+
+	blockClosers = map[string]string{
+		"if":      "end",
+		"foreach": "end",
+		"while":   "end",
+		"define":  "end",
+		"poll":    "until",
+	}
+)
+
+func init() {
+	// the handlers run the statements themselves, hence the late initialization
+	blockHandlers = map[string]blockHandler{
+		"if":      processIf,
+		"foreach": processForeach,
+		"while":   processWhile,
+		"define":  processDefine,
+		"poll":    processPoll,
+	}
+}
+
+func parseScript(script, file string) []statement {
+	lines := strings.Split(script, lineSeparator)
+	statements := make([]statement, 0, len(lines))
+
+	// skip shebang
+	offset := 1
+	if strings.HasPrefix(lines[0], shebang) {
+		lines = lines[1:]
+		offset++
+	}
+
+	command := []string{}
+	first := 0
+	insideCommentBlock := false
+
+	flush := func() {
+		if len(command) > 0 {
+			statements = append(statements, statement{
+				text:  strings.Join(command, " "),
+				lines: command,
+				file:  file,
+				line:  first,
+			})
+		}
+		command = []string{}
+	}
+
+	for lineNumber, line := range lines {
+		lineNumber += offset
+
+		// ignore whitespace
+		line = strings.TrimLeft(line, leadingWhiteSpace)
+		line = strings.TrimRight(line, trainingWhiteSpace)
+
+		if insideCommentBlock {
+			if strings.HasSuffix(line, "*/") {
+				insideCommentBlock = false
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "/*") {
+			if !strings.HasSuffix(line, "*/") {
+				insideCommentBlock = true
+			}
+			continue
+		}
+
+		// ignore comments
+		if strings.HasPrefix(line, commentPrefix) {
+			continue
+		}
+		if pound := strings.Index(line, commentPrefix); pound > 0 {
+			line = strings.TrimSpace(line[:pound])
+		}
+
+		if len(line) == 0 {
+			flush()
+			continue
+		}
+
+		cmd, _ := split(line)
+		if len(command) > 0 && controlsBlock(cmd) {
+			// the start/end of a block also ends the preceding multi-line command
+			flush()
+		}
+
+		if len(command) == 0 {
+			first = lineNumber
+			if !multiLineCommand(cmd) {
+				command = append(command, line)
+				flush()
+				continue
+			}
+		}
+		command = append(command, line)
+	}
+
+	// deal with the remains ...
+	flush()
+	return statements
+}
+
+func controlsBlock(cmd string) bool {
+	name, _ := splitBy(cmd, ":")
+	if _, found := blockClosers[lower(name)]; found {
+		return true
+	}
+	return closesBlock(name)
+}
+
+func closesBlock(cmd string) bool {
+	cmd = lower(cmd)
+	if cmd == "else" {
+		return true
+	}
+	for _, closer := range blockClosers {
+		if cmd == closer {
+			return true
+		}
+	}
+	return false
+}
+
+func runStatements(statements []statement) {
+	for index := 0; index < len(statements); index++ {
+		current := statements[index]
+		locate(current)
+
+		cmd, _ := split(current.text)
+		name, _ := splitBy(cmd, ":")
+		if handler, found := blockHandlers[lower(name)]; found {
+			end := findBlockEnd(statements, index)
+			handler(current, statements[index+1:end], statements[end])
+			index = end
+			continue
+		}
+
+		if closesBlock(name) {
+			quit("[%s] without a matching block", cmd)
+		}
+		processCommand(current.text)
+	}
+}
+
+func locate(current statement) {
+	currentFile = current.file
+	currentLineNumber = current.line
+}
+
+// findBlockEnd returns the index of the statement that closes the block opened at the given index
+func findBlockEnd(statements []statement, index int) int {
+	expected := []string{}
+	for at := index; at < len(statements); at++ {
+		cmd, _ := split(statements[at].text)
+		name, _ := splitBy(cmd, ":")
+		name = lower(name)
+
+		if closer, found := blockClosers[name]; found {
+			expected = append(expected, closer)
+			continue
+		}
+		if len(expected) > 0 && name == expected[len(expected)-1] {
+			expected = expected[:len(expected)-1]
+			if len(expected) == 0 {
+				return at
+			}
+		}
+	}
+
+	locate(statements[index])
+	quit("cannot find the end of the block [%s]", statements[index].text)
+	return len(statements)
+}
+
+// splitBranches breaks the body of the IF block into the branches separated by ELSE
+func splitBranches(body []statement) [][]statement {
+	branches := [][]statement{}
+	depth, start := 0, 0
+	for at, current := range body {
+		cmd, _ := split(current.text)
+		name, _ := splitBy(cmd, ":")
+		name = lower(name)
+
+		switch {
+		case len(blockClosers[name]) > 0:
+			depth++
+		case depth > 0 && closesBlock(name) && name != "else":
+			depth--
+		case depth == 0 && name == "else":
+			branches = append(branches, body[start:at])
+			start = at
+		}
+	}
+	return append(branches, body[start:])
+}
+
+// selectSection splits the statements into the ones preceding the first (top level) SECTION
+// and the ones of the section with the given name
+func selectSection(statements []statement, name string) ([]statement, []statement) {
+	preamble, selected := []statement{}, []statement{}
+	seenSection, inside := false, false
+	for _, current := range statements {
+		cmd, params := split(current.text)
+		if lower(cmd) == "section" {
+			seenSection = true
+			inside = sectionMatches(expand(params), name)
+		}
+		switch {
+		case !seenSection:
+			preamble = append(preamble, current)
+		case inside:
+			selected = append(selected, current)
+		}
+	}
+	return preamble, selected
+}
+
+func sectionMatches(section, pattern string) bool {
+	if matched, err := path.Match(lower(pattern), lower(section)); err == nil && matched {
+		return true
+	}
+	return lower(section) == lower(pattern)
+}
@@ -0,0 +1,286 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimfeld/httptreemux"
+)
+
+// gurl serve [--port 8080] mocks.gurl
+//
+// MAP greeting hello
+//
+// EXPECT GET /users/:id
+// RESPOND 404
+// BODY {"error": "no such user"}
+// RESPOND 200
+// HEADER X-Greeting ${greeting}
+// BODY {"id": "${param:id}", "page": "${query:page}"}
+// END
+//
+// EXPECT POST /users
+// RESPOND 201
+// DELAY 200ms
+// BODY
+// {"name": "${request:name}", "agent": "${header:User-Agent}"}
+// END
+//
+// the paths follow httptreemux (:name for a segment, *name for the rest of the path).
+// the responses of an expectation are served in turn, the last one keeps being served after that.
+// the statements outside of the EXPECT blocks (MAP, LOAD, SET, ...) run once, before the server starts.
+// ${param:...}, ${query:...}, ${header:...} and ${request:...} (the json body, or the text of it)
+// give access to the request being served.
+
+type (
+	mockExpectation struct {
+		method    string
+		path      string
+		file      string
+		line      int
+		responses []*mockResponse
+		calls     int
+	}
+
+	mockResponse struct {
+		status  string
+		headers [][2]string
+		body    []string
+		delay   string
+	}
+)
+
+const mockPortDefault = "8080"
+
+var (
+	mockMu sync.Mutex
+
+	// the commands of an EXPECT block
+	mockKeywords = map[string]bool{
+		"respond": true,
+		"header":  true,
+		"body":    true,
+		"delay":   true,
+		"end":     true,
+	}
+)
+
+func runServe() {
+	data, err := ioutil.ReadFile(filename())
+	quitOnError(err, "Opening file %s", filename())
+	currentFile = filename()
+
+	expectations := parseMocks(parseScript(string(data), currentFile))
+	if len(expectations) == 0 {
+		quit("there are no EXPECT blocks in [%s]", filename())
+	}
+
+	router := httptreemux.New()
+	router.NotFoundHandler = unmatchedRequest
+	router.MethodNotAllowedHandler = func(w http.ResponseWriter, r *http.Request, _ map[string]httptreemux.HandlerFunc) {
+		unmatchedRequest(w, r)
+	}
+	registered := map[string]*mockExpectation{}
+	for _, expectation := range expectations {
+		key := expectation.method + " " + expectation.path
+		if previous, found := registered[key]; found {
+			quit("EXPECT %s is declared twice (%s:%v and %s:%v)", key, previous.file, previous.line, expectation.file, expectation.line)
+		}
+		registered[key] = expectation
+		router.Handle(expectation.method, expectation.path, expectation.serve)
+	}
+
+	port, _ := optionValue("--port", "-port")
+	if len(port) == 0 {
+		port = mockPortDefault
+	}
+	address := port
+	if !strings.Contains(address, ":") {
+		address = ":" + address
+	}
+	comment(echoProgress, "serving %v expectations from %s on %s", len(expectations), filename(), address)
+	quitOnError(http.ListenAndServe(address, router), "Serving on [%s]", address)
+	exit(exitCodeOnSuccess)
+}
+
+// parseMocks runs the top level statements and collects the EXPECT blocks
+func parseMocks(statements []statement) []*mockExpectation {
+	expectations, preamble := []*mockExpectation{}, []statement{}
+	var expectation *mockExpectation
+	var current *mockResponse
+	inBody := false
+
+	for _, one := range statements {
+		locate(one)
+		cmd, params := split(one.text)
+		keyword := lower(cmd)
+
+		if expectation == nil {
+			if keyword != "expect" {
+				preamble = append(preamble, one)
+				continue
+			}
+			method, path := split(params)
+			if len(method) == 0 || !strings.HasPrefix(path, "/") {
+				quit("EXPECT expects: method /path, got [%s]", params)
+			}
+			expectation = &mockExpectation{method: strings.ToUpper(method), path: path, file: one.file, line: one.line}
+			current, inBody = nil, false
+			continue
+		}
+
+		if inBody && !mockKeywords[keyword] {
+			// the lines following BODY
+			current.body = append(current.body, one.lines...)
+			continue
+		}
+		inBody = false
+
+		if keyword != "respond" && keyword != "end" && current == nil {
+			// the first RESPOND may be left out
+			current = &mockResponse{status: strconv.Itoa(http.StatusOK)}
+			expectation.responses = append(expectation.responses, current)
+		}
+		switch keyword {
+		case "respond":
+			status := params
+			if len(status) == 0 {
+				status = strconv.Itoa(http.StatusOK)
+			}
+			current = &mockResponse{status: status}
+			expectation.responses = append(expectation.responses, current)
+		case "header":
+			name, value := split(params)
+			if len(name) == 0 {
+				quit("HEADER expects: name value")
+			}
+			current.headers = append(current.headers, [2]string{name, value})
+		case "body":
+			if len(params) > 0 {
+				current.body = append(current.body, params)
+			}
+			if external, name := dataPointsToExternalFile(params); external && !strings.Contains(name, "${") {
+				_, err := os.Stat(name)
+				quitOnError(err, "Opening file [%s]", name)
+			}
+			inBody = true
+		case "delay":
+			_, err := time.ParseDuration(params)
+			quitOnError(err, "Parsing DELAY [%s]", params)
+			current.delay = params
+		case "end":
+			if len(expectation.responses) == 0 {
+				expectation.responses = append(expectation.responses, &mockResponse{status: strconv.Itoa(http.StatusOK)})
+			}
+			expectations = append(expectations, expectation)
+			expectation = nil
+		default:
+			quit("unknown command [%s] in the EXPECT block", cmd)
+		}
+	}
+	if expectation != nil {
+		quit("cannot find the end of the block [EXPECT %s %s] (%s:%v)", expectation.method, expectation.path, expectation.file, expectation.line)
+	}
+	runStatements(preamble)
+	return expectations
+}
+
+func (expectation *mockExpectation) serve(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	// the variables (and the counters) are shared by all the requests
+	mockMu.Lock()
+	index := expectation.calls
+	if index >= len(expectation.responses) {
+		index = len(expectation.responses) - 1
+	}
+	expectation.calls++
+	current := expectation.responses[index]
+
+	pushScope(mockScope(r, body, params))
+	status, err := strconv.Atoi(expand(current.status))
+	if err != nil {
+		reportError(err, "Parsing the status [%s] of EXPECT %s %s", current.status, expectation.method, expectation.path)
+		status = http.StatusInternalServerError
+	}
+	headers := make([][2]string, 0, len(current.headers))
+	for _, header := range current.headers {
+		headers = append(headers, [2]string{header[0], expand(header[1])})
+	}
+	text := strings.Join(current.body, lineSeparator)
+	if external, name := dataPointsToExternalFile(text); external {
+		data, err := ioutil.ReadFile(expand(name))
+		if err != nil {
+			reportError(err, "Opening file [%s]", name)
+		}
+		text = string(data)
+	}
+	text = expand(text)
+	popScope()
+	mockMu.Unlock()
+
+	if len(current.delay) > 0 {
+		delay, _ := time.ParseDuration(current.delay)
+		time.Sleep(delay)
+	}
+	for _, header := range headers {
+		w.Header().Add(header[0], header[1])
+	}
+	if len(w.Header().Get(headerContentType)) == 0 && json.Valid([]byte(text)) && len(strings.TrimSpace(text)) > 0 {
+		w.Header().Set(headerContentType, contentTypeJson)
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(text))
+
+	comment(echoProgress, "%s %s -> %v (EXPECT %s %s, response %v of %v)", r.Method, r.URL.RequestURI(), status, expectation.method, expectation.path, index+1, len(expectation.responses))
+}
+
+// mockScope binds the parts of the request being served
+func mockScope(r *http.Request, body []byte, params map[string]string) msi {
+	parameters := msi{}
+	for name, value := range params {
+		parameters[name] = value
+	}
+	query := msi{}
+	for name, values := range r.URL.Query() {
+		query[name] = strings.Join(values, ", ")
+	}
+	headers := msi{}
+	for name, values := range r.Header {
+		headers[name] = strings.Join(values, ", ")
+		headers[lower(name)] = headers[name]
+	}
+	var request interface{} = string(body)
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err == nil {
+		request = document
+	}
+	return msi{
+		"param":   parameters,
+		"query":   query,
+		"header":  headers,
+		"request": request,
+		"method":  r.Method,
+		"path":    r.URL.Path,
+	}
+}
+
+func unmatchedRequest(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	preview := oneLine(string(body))
+	if len(preview) > pollBodyPreviewSize {
+		preview = preview[:pollBodyPreviewSize] + "..."
+	}
+	responseAttention("unmatched request: %s %s %s", r.Method, r.URL.RequestURI(), preview)
+	http.Error(w, "gurl: no expectation matches "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// gurl -session state.json login.gurl
+// gurl -session state.json work.gurl
+//   the cookies and the variables (MAP, LOAD) are loaded before and saved after the run
+
+type (
+	sessionCookie struct {
+		Name     string `json:"name"`
+		Value    string `json:"value"`
+		Path     string `json:"path,omitempty"`
+		Domain   string `json:"domain,omitempty"`
+		Expires  string `json:"expires,omitempty"`
+		Secure   bool   `json:"secure,omitempty"`
+		HttpOnly bool   `json:"httpOnly,omitempty"`
+	}
+
+	sessionState struct {
+		Cookies   map[string][]sessionCookie `json:"cookies"`
+		Variables map[string]string          `json:"variables"`
+	}
+)
+
+var (
+	sessionFile = ""
+
+	// variables set by the script (as opposed to the built-in ones)
+	variables = map[string]string{}
+)
+
+func setVariable(key, value string) {
+	if isOverridden(configurationVariablePrefix + key) {
+		return
+	}
+	variables[key] = value
+	resolver.Add(key, value)
+}
+
+func enableSession(file string) {
+	sessionFile = file
+	onExit(saveSession)
+}
+
+func loadSession() {
+	if len(sessionFile) == 0 {
+		return
+	}
+
+	data, err := ioutil.ReadFile(sessionFile)
+	if os.IsNotExist(err) {
+		debug("session file [%s] does not exist yet", sessionFile)
+		return
+	}
+	quitOnError(err, "Reading session file [%s]", sessionFile)
+
+	var state sessionState
+	quitOnError(json.Unmarshal(data, &state), "Parsing session file [%s]", sessionFile)
+
+	for key, value := range state.Variables {
+		setVariable(key, value)
+	}
+	for target, cookies := range state.Cookies {
+		u, err := url.Parse(target)
+		if err != nil {
+			reportError(err, "Parsing url [%s] of session file [%s]", target, sessionFile)
+			continue
+		}
+		restored := make([]*http.Cookie, 0, len(cookies))
+		for _, cookie := range cookies {
+			one := &http.Cookie{Name: cookie.Name, Value: cookie.Value, Path: cookie.Path, Domain: cookie.Domain, Secure: cookie.Secure, HttpOnly: cookie.HttpOnly}
+			if len(one.Path) == 0 {
+				one.Path = "/"
+			}
+			if len(cookie.Expires) > 0 {
+				one.Expires, _ = time.Parse(time.RFC3339, cookie.Expires)
+			}
+			restored = append(restored, one)
+		}
+		cookieJar.SetCookies(u, restored)
+	}
+	comment(echoProgress, "loaded session from %s", sessionFile)
+}
+
+func saveSession() {
+	if loadWorker || loadTesting() {
+		// the load test workers share the session file, so they (and the load test itself) only read it
+		return
+	}
+	state := sessionState{
+		Cookies:   make(map[string][]sessionCookie),
+		Variables: variables,
+	}
+	for target, cookies := range cookieJar.all() {
+		for _, cookie := range cookies {
+			saved := sessionCookie{Name: cookie.Name, Value: cookie.Value, Path: cookie.Path, Domain: cookie.Domain, Secure: cookie.Secure, HttpOnly: cookie.HttpOnly}
+			if !cookie.Expires.IsZero() {
+				saved.Expires = cookie.Expires.UTC().Format(time.RFC3339)
+			}
+			state.Cookies[target] = append(state.Cookies[target], saved)
+		}
+	}
+
+	data, err := json.MarshalIndent(&state, marshalPrefix, marshalIndent)
+	if err != nil {
+		reportError(err, "Marshalling session")
+		return
+	}
+	if err := ioutil.WriteFile(sessionFile, data, 0600); err != nil {
+		reportError(err, "Saving session to [%s]", sessionFile)
+		return
+	}
+	comment(echoProgress, "saved session to %s", sessionFile)
+}
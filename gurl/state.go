@@ -12,9 +12,11 @@ var (
 
 	headers              = map[string]string{}
 	printResponseHeaders = printResponseHeadersDefault
+	printResponseBody    = printResponseBodyDefault
 	generateCurlCommands = generateCurlCommandsDefault
 	collectTimingInfo    = collectTimingInfoDefault
 	resolveExternalFiles = resolveExternalFilesDefault
+	useCookieJar         = useCookieJarDefault
 
 	echoSilent         = false
 	echoDebug          = false
@@ -24,12 +26,25 @@ var (
 	echoGetCommand     = echoDefault
 	echoPostCommand    = echoDefault
 	echoPatchCommand   = echoDefault
+	echoPutCommand     = echoDefault
 	echoDeleteCommand  = echoDefault
+	echoHeadCommand    = echoDefault
+	echoOptionsCommand = echoDefault
+	echoRequestCommand = echoDefault
 	echoHeaderCommand  = echoDefault
 	echoEchoCommand    = true
 	echoRequireCommand = echoDefault
 	echoLoadCommand    = echoDefault
 	echoSectionCommand = echoDefault
+	echoIfCommand      = echoDefault
+	echoForeachCommand = echoDefault
+	echoWhileCommand   = echoDefault
+	echoIncludeCommand = echoDefault
+	echoDefineCommand  = echoDefault
+	echoCallCommand    = echoDefault
+	echoPollCommand    = echoDefault
+	echoCookieCommand  = echoDefault
+	echoAuthCommand    = echoDefault
 
 	resolver = resolve.New()
 
@@ -37,9 +52,14 @@ var (
 	currentLineNumber = 0
 	currentCommand    = ""
 
+	// -section name
+	runSection = ""
+
 	responsePrettyPrintBody = responsePrettyPrintBodyDefault
 
 	incrementalCounter int64
+
+	exitHandlers = []func(){}
 )
 
 const (
@@ -49,29 +69,44 @@ const (
 var (
 	dials = map[string]*bool{
 		"print.response.headers": &printResponseHeaders,
+		"print.response.body":    &printResponseBody,
 		//	"generate.curl.commands": &generateCurlCommands,
 		"collect.timing.info":    &collectTimingInfo,
 		"resolve.external.files": &resolveExternalFiles,
 		"pretty.print.body":      &responsePrettyPrintBody,
+		"insecure.skip.verify":   &skipTlsVerify,
+		"follow.redirects":       &followRedirects,
+		"http2":                  &enableHttp2,
+		"cookie.jar":             &useCookieJar,
+		"replay.ignore.host":     &replayIgnoreHost,
 
 		echoPrefix + "map":      &echoMapCommand,
 		echoPrefix + "set":      &echoSetCommand,
 		echoPrefix + "get":      &echoGetCommand,
 		echoPrefix + "post":     &echoPostCommand,
 		echoPrefix + "patch":    &echoPatchCommand,
+		echoPrefix + "put":      &echoPutCommand,
 		echoPrefix + "delete":   &echoDeleteCommand,
+		echoPrefix + "head":     &echoHeadCommand,
+		echoPrefix + "options":  &echoOptionsCommand,
+		echoPrefix + "request":  &echoRequestCommand,
 		echoPrefix + "header":   &echoHeaderCommand,
 		echoPrefix + "progress": &echoProgress,
 		echoPrefix + "echo":     &echoEchoCommand,
 		echoPrefix + "require":  &echoRequireCommand,
 		echoPrefix + "load":     &echoLoadCommand,
+		echoPrefix + "if":       &echoIfCommand,
+		echoPrefix + "foreach":  &echoForeachCommand,
+		echoPrefix + "while":    &echoWhileCommand,
+		echoPrefix + "include":  &echoIncludeCommand,
+		echoPrefix + "define":   &echoDefineCommand,
+		echoPrefix + "call":     &echoCallCommand,
+		echoPrefix + "poll":     &echoPollCommand,
+		echoPrefix + "cookie":   &echoCookieCommand,
+		echoPrefix + "auth":     &echoAuthCommand,
 	}
 )
 
-var (
-	savedResponse []byte
-)
-
 func offline() bool {
-	return generateCurlCommands
+	return generateCurlCommands || exporting
 }
@@ -0,0 +1,388 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gurl test [-parallel 4] [-run Checkout*] [-tags smoke,!slow] ./api-tests/... [more.gurl]
+//
+// every *.gurl file found is run by a separate gurl process (in its own directory, so each has its own
+// resolver state). a directory may have setup.gurl, which runs before its scripts, and teardown.gurl,
+// which runs after them. the cookies and the variables (MAP) setup.gurl ends up with are handed
+// to the scripts (and to teardown.gurl) as their -session.
+//
+// -run keeps the scripts having a matching SECTION (and runs only those sections of them),
+// -tags keeps the scripts having one of the tags (and none of the !tags) in their header comments:
+//
+// # tags: smoke, users
+
+type (
+	suiteDirectory struct {
+		path     string
+		setup    string
+		teardown string
+		scripts  []string
+	}
+
+	suiteResult struct {
+		script   string
+		passed   bool
+		skipped  string
+		output   string
+		duration time.Duration
+	}
+)
+
+const (
+	suiteSetup     = "setup.gurl"
+	suiteTeardown  = "teardown.gurl"
+	suiteExtension = ".gurl"
+	suiteTagsLabel = "tags:"
+	suiteRecursive = "..."
+)
+
+func runTests() {
+	parallel := 1
+	if value, found := optionValue("-parallel"); found {
+		if _, err := fmt.Sscanf(value, "%d", &parallel); err != nil || parallel <= 0 {
+			quit("-parallel expects a positive number, got [%s]", value)
+		}
+	}
+	pattern, _ := optionValue("-run")
+	tags, _ := optionValue("-tags")
+
+	targets := positionalArguments()
+	if len(targets) == 0 {
+		targets = []string{"." + string(filepath.Separator) + suiteRecursive}
+	}
+	directories, filtered := discoverScripts(targets, pattern, splitList(tags))
+	if len(directories) == 0 {
+		quit("there are no scripts to run in %s", strings.Join(targets, ", "))
+	}
+
+	executable, err := os.Executable()
+	quitOnError(err, "Locating gurl executable")
+	folder, err := ioutil.TempDir("", "gurl-test-")
+	quitOnError(err, "Creating temporary folder")
+	defer os.RemoveAll(folder)
+
+	started := time.Now()
+	results := []suiteResult{}
+	for at, directory := range directories {
+		results = append(results, directory.run(executable, filepath.Join(folder, fmt.Sprintf("session-%v", at)), pattern, parallel)...)
+	}
+
+	passed, failed, skipped := 0, 0, filtered
+	for _, result := range results {
+		switch {
+		case len(result.skipped) > 0:
+			skipped++
+		case result.passed:
+			passed++
+		default:
+			failed++
+		}
+	}
+	summary := responseSuccess
+	if failed > 0 {
+		summary = responseFailure
+	}
+	summary("Summary: %v scripts, %v passed, %v failed, %v skipped (%s)", passed+failed+skipped, passed, failed, skipped, time.Since(started).Round(time.Millisecond))
+	if failed > 0 {
+		exit(exitCodeOnFailure)
+	}
+	exit(exitCodeOnSuccess)
+}
+
+// positionalArguments returns the script name and the rest of the arguments that are not options
+func positionalArguments() []string {
+	result := []string{}
+	if len(scriptName) > 0 {
+		result = append(result, scriptName)
+	}
+	for i := 0; i < len(cmdLineOptions); i++ {
+		option := cmdLineOptions[i]
+		switch {
+		case strings.HasPrefix(option, "-"):
+			if valueOptions[lower(option)] {
+				i++
+			}
+		default:
+			result = append(result, option)
+		}
+	}
+	return result
+}
+
+// discoverScripts groups the selected scripts by their directories, and counts the ones left out
+func discoverScripts(targets []string, pattern string, tags []string) ([]*suiteDirectory, int) {
+	byPath := map[string]*suiteDirectory{}
+	filtered := 0
+	add := func(script string) {
+		switch filepath.Base(script) {
+		case suiteSetup, suiteTeardown:
+			return
+		}
+		if !scriptSelected(script, pattern, tags) {
+			filtered++
+			return
+		}
+		path := filepath.Dir(script)
+		directory, found := byPath[path]
+		if !found {
+			directory = &suiteDirectory{path: path, setup: existingFile(path, suiteSetup), teardown: existingFile(path, suiteTeardown)}
+			byPath[path] = directory
+		}
+		directory.scripts = append(directory.scripts, script)
+	}
+
+	for _, target := range targets {
+		root, recursive := target, false
+		if strings.HasSuffix(target, suiteRecursive) {
+			root, recursive = filepath.Clean(strings.TrimSuffix(target, suiteRecursive)), true
+		}
+		info, err := os.Stat(root)
+		quitOnError(err, "Locating [%s]", target)
+		if !info.IsDir() {
+			add(filepath.Clean(root))
+			continue
+		}
+		err = filepath.Walk(root, func(name string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				// the hidden ones (.git) and _ ones are left alone, as go does
+				base := info.Name()
+				if name != root && (!recursive || strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(name, suiteExtension) {
+				add(name)
+			}
+			return nil
+		})
+		quitOnError(err, "Walking [%s]", target)
+	}
+
+	directories := make([]*suiteDirectory, 0, len(byPath))
+	for _, directory := range byPath {
+		sort.Strings(directory.scripts)
+		directories = append(directories, directory)
+	}
+	sort.Slice(directories, func(i, j int) bool { return directories[i].path < directories[j].path })
+	return directories, filtered
+}
+
+func existingFile(path, name string) string {
+	name = filepath.Join(path, name)
+	if _, err := os.Stat(name); err != nil {
+		return ""
+	}
+	return name
+}
+
+func scriptSelected(script, pattern string, tags []string) bool {
+	if len(pattern) > 0 && !hasSection(script, pattern) {
+		return false
+	}
+	if len(tags) == 0 {
+		return true
+	}
+	own := scriptTags(script)
+	selected := false
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, "!") {
+			if own[lower(tag[1:])] {
+				return false
+			}
+			continue
+		}
+		selected = selected || own[lower(tag)]
+	}
+	return selected || allExclusions(tags)
+}
+
+func allExclusions(tags []string) bool {
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, "!") {
+			return false
+		}
+	}
+	return true
+}
+
+// scriptTags reads the # tags: lines of the header comments (the ones preceding the first command)
+func scriptTags(script string) map[string]bool {
+	tags := map[string]bool{}
+	file, err := os.Open(script)
+	if err != nil {
+		return tags
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, shebang) {
+			continue
+		}
+		if !strings.HasPrefix(line, commentPrefix) {
+			break
+		}
+		text := strings.TrimSpace(strings.TrimPrefix(line, commentPrefix))
+		if strings.HasPrefix(lower(text), suiteTagsLabel) {
+			for _, tag := range splitList(text[len(suiteTagsLabel):]) {
+				tags[lower(tag)] = true
+			}
+		}
+	}
+	return tags
+}
+
+func hasSection(script, pattern string) bool {
+	data, err := ioutil.ReadFile(script)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if comment := strings.Index(line, commentPrefix); comment >= 0 {
+			line = line[:comment]
+		}
+		if cmd, params := split(strings.TrimSpace(line)); lower(cmd) == "section" && sectionMatches(params, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs setup, then the scripts (parallel at a time), then teardown
+func (directory *suiteDirectory) run(executable, session, pattern string, parallel int) []suiteResult {
+	results := []suiteResult{}
+	setupPassed := true
+	if len(directory.setup) > 0 {
+		result := runSuiteScript(executable, directory.setup, session+".json", "")
+		results = append(results, result)
+		printSuiteResult(result)
+		setupPassed = result.passed
+	}
+	shared := existingFile(filepath.Dir(session), filepath.Base(session)+".json")
+
+	scripts := make([]suiteResult, len(directory.scripts))
+	var wait sync.WaitGroup
+	slots := make(chan bool, parallel)
+	for at, script := range directory.scripts {
+		if !setupPassed {
+			scripts[at] = suiteResult{script: script, skipped: "setup failed"}
+			continue
+		}
+		wait.Add(1)
+		slots <- true
+		go func(at int, script string) {
+			defer func() { <-slots; wait.Done() }()
+			scripts[at] = runSuiteScript(executable, script, sessionCopy(shared, fmt.Sprintf("%s-%v.json", session, at)), pattern)
+		}(at, script)
+	}
+	wait.Wait()
+	for _, result := range scripts {
+		printSuiteResult(result)
+	}
+	results = append(results, scripts...)
+
+	if len(directory.teardown) > 0 {
+		result := runSuiteScript(executable, directory.teardown, sessionCopy(shared, session+"-teardown.json"), "")
+		printSuiteResult(result)
+		results = append(results, result)
+	}
+	return results
+}
+
+// sessionCopy gives every script a copy of its own (so they do not overwrite one another's)
+func sessionCopy(shared, session string) string {
+	if len(shared) == 0 {
+		return ""
+	}
+	data, err := ioutil.ReadFile(shared)
+	quitOnError(err, "Reading session file [%s]", shared)
+	quitOnError(ioutil.WriteFile(session, data, 0600), "Writing session file [%s]", session)
+	return session
+}
+
+// runSuiteScript runs the script in its directory
+func runSuiteScript(executable, script, session, pattern string) suiteResult {
+	args := []string{filepath.Base(script)}
+	if len(session) > 0 {
+		args = append(args, "-session", session)
+	}
+	if len(pattern) > 0 {
+		args = append(args, "-section", pattern)
+	}
+	args = append(args, suiteOptions()...)
+
+	started := time.Now()
+	child := exec.Command(executable, args...)
+	child.Dir = filepath.Dir(script)
+	if len(environmentsLocation) > 0 {
+		// the scripts run in their own directories, so they are given the environments file found here
+		location, _ := filepath.Abs(environmentsLocation)
+		child.Env = append(os.Environ(), envEnvironmentLocation+"="+location)
+	}
+	output, err := child.CombinedOutput()
+	return suiteResult{script: script, passed: err == nil, output: string(output), duration: time.Since(started)}
+}
+
+// suiteOptions passes the relevant command line options to the scripts
+func suiteOptions() []string {
+	result := []string{}
+	for i := 0; i < len(cmdLineOptions); i++ {
+		option := lower(cmdLineOptions[i])
+		switch {
+		case !strings.HasPrefix(option, "-"):
+			// one of the targets
+		case option == "-parallel" || option == "-run" || option == "-tags" || option == "-section" ||
+			option == "-session" || option == "-report" || option == "-history" || option == "-load" ||
+			option == "-record" || option == "-replay":
+			i++ // skip the value as well
+		case option == "-secrets" && i+1 < len(cmdLineOptions):
+			// the scripts run in their own directories
+			i++
+			secrets, _ := filepath.Abs(cmdLineOptions[i])
+			result = append(result, cmdLineOptions[i-1], secrets)
+		case valueOptions[option] && i+1 < len(cmdLineOptions):
+			result = append(result, cmdLineOptions[i], cmdLineOptions[i+1])
+			i++
+		default:
+			result = append(result, cmdLineOptions[i])
+		}
+	}
+	return result
+}
+
+func printSuiteResult(result suiteResult) {
+	switch {
+	case len(result.skipped) > 0:
+		report("SKIP  %s  (%s)", result.script, result.skipped)
+	case result.passed:
+		responseSuccess("PASS  %s  (%s)", result.script, result.duration.Round(time.Millisecond))
+	default:
+		responseFailure("FAIL  %s  (%s)", result.script, result.duration.Round(time.Millisecond))
+		for _, line := range strings.Split(strings.TrimRight(result.output, "\n"), "\n") {
+			fmt.Println("    " + line)
+		}
+	}
+}
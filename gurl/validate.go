@@ -0,0 +1,401 @@
+// Copyright 2019 Seamia Corporation. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/spec"
+)
+
+// SET openapi.spec ./api.yaml
+//
+// every following response is checked against the matching operation of the spec: the status
+// has to be a documented one (by itself, by its range like 2XX, or by default), the (json) body
+// has to match the schema and the required headers have to be present. the violations are
+// reported as failed REQUIREs.
+// the coverage of the operations is printed at the end of the run.
+
+type (
+	apiValidation struct {
+		source     string
+		swagger    *spec.Swagger
+		operations []*coveredOperation
+		unknown    map[string]int
+	}
+
+	coveredOperation struct {
+		apiOperation
+		pattern *regexp.Regexp
+		hits    int
+	}
+)
+
+var (
+	apiSpec *apiValidation
+
+	// the formats worth checking (the others are accepted as they are)
+	formatPatterns = map[string]*regexp.Regexp{
+		"uuid":  regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`),
+		"email": regexp.MustCompile(`^[^@\s]+@[^@\s]+$`),
+		"date":  regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`),
+	}
+)
+
+func useApiSpec(name string) {
+	if len(name) == 0 {
+		apiSpec = nil
+		return
+	}
+	if apiSpec == nil && !loadWorker {
+		onExit(printApiCoverage)
+	}
+
+	swagger := loadApiSpec(name)
+	validation := &apiValidation{source: name, swagger: swagger, unknown: map[string]int{}}
+	for _, operation := range apiOperations(swagger) {
+		template := regexp.QuoteMeta(operation.path)
+		template = strings.NewReplacer(`\{`, `{`, `\}`, `}`).Replace(template)
+		template = pathParameterPattern.ReplaceAllString(template, `[^/]+`)
+		validation.operations = append(validation.operations, &coveredOperation{
+			apiOperation: operation,
+			pattern:      regexp.MustCompile("^" + template + "/?$"),
+		})
+	}
+	// the literal paths win over the templated ones (/pets/mine over /pets/{id})
+	sort.SliceStable(validation.operations, func(i, j int) bool {
+		return strings.Count(validation.operations[i].path, "{") < strings.Count(validation.operations[j].path, "{")
+	})
+	apiSpec = validation
+	comment(echoSetCommand, "validating the responses against %s (%v operations)", name, len(validation.operations))
+}
+
+// validateExchange checks the response against the spec (when there is one)
+func validateExchange(current *exchange, bodyRead bool) {
+	if apiSpec == nil || current == nil {
+		return
+	}
+	target, err := url.Parse(current.url)
+	if err != nil {
+		return
+	}
+	path := target.Path
+	if base := strings.TrimSuffix(apiSpec.swagger.BasePath, "/"); len(base) > 0 {
+		path = strings.TrimPrefix(path, base)
+	}
+
+	operation := apiSpec.match(current.method, path)
+	if operation == nil {
+		apiSpec.unknown[current.method+" "+path]++
+		responseAttention("openapi: %s %s is not in the spec", current.method, path)
+		return
+	}
+	operation.hits++
+	name := operationName(operation.apiOperation)
+
+	response, documented := spec.Response{}, false
+	if responses := operation.operation.Responses; responses != nil {
+		if found, ok := responses.StatusCodeResponses[current.status]; ok {
+			response, documented = found, true
+		} else if found, ok := statusRangeResponse(responses, current.status); ok {
+			response, documented = found, true
+		} else if responses.Default != nil {
+			response, documented = *responses.Default, true
+		}
+	}
+	if !documented {
+		checkFailed("openapi: %s returned status %v, which is not documented", name, current.status)
+		return
+	}
+	response = resolveResponse(apiSpec.swagger, response)
+
+	violations := []string{}
+	for header, details := range response.Headers {
+		if required, _ := details.Extensions.GetBool("x-required"); required && len(current.header.Get(header)) == 0 {
+			violations = append(violations, fmt.Sprintf("the header [%s] is missing", header))
+		}
+	}
+
+	if response.Schema != nil && bodyRead && current.method != "HEAD" {
+		contentType := lower(current.header.Get(headerContentType))
+		switch {
+		case len(current.body) == 0:
+			violations = append(violations, "the body is empty")
+		case strings.Contains(contentType, "json") || len(contentType) == 0:
+			var body interface{}
+			if err := json.Unmarshal(current.body, &body); err != nil {
+				violations = append(violations, "the body is not json: "+err.Error())
+			} else {
+				violations = append(violations, validateSchema(apiSpec.swagger, response.Schema, body, "$", 0)...)
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		checkPassed()
+		return
+	}
+	for _, violation := range violations {
+		checkFailed("openapi: %s (status %v): %s", name, current.status, violation)
+	}
+}
+
+// statusRangeResponse finds the response documented for the range of the status (2XX of OpenAPI 3)
+func statusRangeResponse(responses *spec.Responses, status int) (spec.Response, bool) {
+	found, ok := asMap(responses.Extensions[statusRangesExtension])[fmt.Sprintf("%dXX", status/100)]
+	if !ok {
+		return spec.Response{}, false
+	}
+	var response spec.Response
+	data, err := json.Marshal(found)
+	if err == nil {
+		err = json.Unmarshal(data, &response)
+	}
+	if err != nil {
+		debug("cannot use the response of %vXX: %v", status/100, err)
+		return spec.Response{}, false
+	}
+	return response, true
+}
+
+func (validation *apiValidation) match(method, path string) *coveredOperation {
+	for _, operation := range validation.operations {
+		if operation.method == method && operation.pattern.MatchString(path) {
+			return operation
+		}
+	}
+	return nil
+}
+
+// validateSchema returns the ways the value does not match the schema
+func validateSchema(swagger *spec.Swagger, schema *spec.Schema, value interface{}, at string, depth int) []string {
+	schema = resolveSchema(swagger, schema)
+	if schema == nil || depth > maxRefDepth {
+		return nil
+	}
+	fail := func(format string, a ...interface{}) []string {
+		return []string{at + ": " + fmt.Sprintf(format, a...)}
+	}
+
+	if value == nil {
+		nullable, _ := schema.Extensions.GetBool("x-nullable")
+		if schema.Nullable || nullable || schema.Type.Contains("null") || len(schema.Type) == 0 {
+			return nil
+		}
+		return fail("null is not allowed")
+	}
+
+	violations := []string{}
+	for part := range schema.AllOf {
+		violations = append(violations, validateSchema(swagger, &schema.AllOf[part], value, at, depth+1)...)
+	}
+	for _, alternatives := range [][]spec.Schema{schema.OneOf, schema.AnyOf} {
+		if len(alternatives) == 0 {
+			continue
+		}
+		matched := false
+		for one := range alternatives {
+			if len(validateSchema(swagger, &alternatives[one], value, at, depth+1)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			violations = append(violations, at+": matches none of the alternatives")
+		}
+	}
+
+	if len(schema.Enum) > 0 {
+		allowed := false
+		for _, one := range schema.Enum {
+			allowed = allowed || fmt.Sprint(one) == fmt.Sprint(value)
+		}
+		if !allowed {
+			return append(violations, fail("[%v] is not one of %v", value, schema.Enum)...)
+		}
+	}
+
+	switch expected := schemaType(schema); expected {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return append(violations, fail("expected an object, got %s", jsonType(value))...)
+		}
+		for _, name := range schema.Required {
+			if _, found := object[name]; !found {
+				violations = append(violations, fail("the property [%s] is missing", name)...)
+			}
+		}
+		for _, name := range sortedKeys(object) {
+			property, known := schema.Properties[name]
+			switch {
+			case known:
+				violations = append(violations, validateSchema(swagger, &property, object[name], at+"."+name, depth+1)...)
+			case schema.AdditionalProperties == nil:
+			case schema.AdditionalProperties.Schema != nil:
+				violations = append(violations, validateSchema(swagger, schema.AdditionalProperties.Schema, object[name], at+"."+name, depth+1)...)
+			case !schema.AdditionalProperties.Allows:
+				violations = append(violations, fail("the property [%s] is not allowed", name)...)
+			}
+		}
+
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return append(violations, fail("expected an array, got %s", jsonType(value))...)
+		}
+		if schema.MinItems != nil && int64(len(array)) < *schema.MinItems {
+			violations = append(violations, fail("expected at least %v items, got %v", *schema.MinItems, len(array))...)
+		}
+		if schema.MaxItems != nil && int64(len(array)) > *schema.MaxItems {
+			violations = append(violations, fail("expected at most %v items, got %v", *schema.MaxItems, len(array))...)
+		}
+		if schema.Items != nil {
+			for index, item := range array {
+				itemSchema := schema.Items.Schema
+				if itemSchema == nil && index < len(schema.Items.Schemas) {
+					itemSchema = &schema.Items.Schemas[index]
+				}
+				violations = append(violations, validateSchema(swagger, itemSchema, item, fmt.Sprintf("%s[%v]", at, index), depth+1)...)
+			}
+		}
+
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return append(violations, fail("expected a string, got %s", jsonType(value))...)
+		}
+		length := int64(len([]rune(text)))
+		if schema.MinLength != nil && length < *schema.MinLength {
+			violations = append(violations, fail("expected at least %v characters, got %v", *schema.MinLength, length)...)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			violations = append(violations, fail("expected at most %v characters, got %v", *schema.MaxLength, length)...)
+		}
+		if len(schema.Pattern) > 0 {
+			if pattern, err := regexp.Compile(schema.Pattern); err == nil && !pattern.MatchString(text) {
+				violations = append(violations, fail("[%s] does not match [%s]", text, schema.Pattern)...)
+			}
+		}
+		if !validFormat(schema.Format, text) {
+			violations = append(violations, fail("[%s] is not a valid %s", text, schema.Format)...)
+		}
+
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok {
+			return append(violations, fail("expected a number, got %s", jsonType(value))...)
+		}
+		if expected == "integer" && number != math.Trunc(number) {
+			return append(violations, fail("expected an integer, got %v", number)...)
+		}
+		if schema.Minimum != nil && (number < *schema.Minimum || (schema.ExclusiveMinimum && number == *schema.Minimum)) {
+			violations = append(violations, fail("%v is below the minimum of %v", number, *schema.Minimum)...)
+		}
+		if schema.Maximum != nil && (number > *schema.Maximum || (schema.ExclusiveMaximum && number == *schema.Maximum)) {
+			violations = append(violations, fail("%v is above the maximum of %v", number, *schema.Maximum)...)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return append(violations, fail("expected a boolean, got %s", jsonType(value))...)
+		}
+	}
+	return violations
+}
+
+func validFormat(format, value string) bool {
+	switch lower(format) {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "uri", "url":
+		parsed, err := url.Parse(value)
+		return err == nil && len(parsed.Scheme) > 0
+	}
+	if pattern, found := formatPatterns[lower(format)]; found {
+		return pattern.MatchString(value)
+	}
+	return true
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return "null"
+}
+
+func printApiCoverage() {
+	if apiSpec == nil || offline() {
+		return
+	}
+	covered := 0
+	for _, operation := range apiSpec.operations {
+		if operation.hits > 0 {
+			covered++
+		}
+	}
+	percent := 0.0
+	if total := len(apiSpec.operations); total > 0 {
+		percent = 100 * float64(covered) / float64(total)
+	}
+
+	// back in the order of the spec
+	operations := append([]*coveredOperation{}, apiSpec.operations...)
+	sort.SliceStable(operations, func(i, j int) bool {
+		if operations[i].path != operations[j].path {
+			return operations[i].path < operations[j].path
+		}
+		return methodOrder(operations[i].method) < methodOrder(operations[j].method)
+	})
+
+	report("OpenAPI coverage of %s: %v of %v operations (%.0f%%)", filepath.Base(apiSpec.source), covered, len(operations), percent)
+	for _, operation := range operations {
+		status, hits := "MISS", ""
+		if operation.hits > 0 {
+			status, hits = "HIT", fmt.Sprint(operation.hits)
+		}
+		report("  %-4s %5s  %-7s %-40s %s", status, hits, operation.method, operation.path, operation.operation.ID)
+	}
+	for _, request := range sortedCounts(apiSpec.unknown) {
+		responseAttention("  %-4s %5v  %s (not in the spec)", "----", apiSpec.unknown[request], request)
+	}
+}
+
+func methodOrder(method string) int {
+	for at, one := range apiMethods {
+		if one == method {
+			return at
+		}
+	}
+	return len(apiMethods)
+}
+
+func sortedCounts(src map[string]int) []string {
+	keys := make([]string, 0, len(src))
+	for key := range src {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}